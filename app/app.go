@@ -1,6 +1,8 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -8,6 +10,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -15,6 +18,7 @@ import (
 	"github.com/beck-8/subs-check/assets"
 	"github.com/beck-8/subs-check/check"
 	"github.com/beck-8/subs-check/config"
+	proxyutils "github.com/beck-8/subs-check/proxy"
 	"github.com/beck-8/subs-check/save"
 	"github.com/beck-8/subs-check/utils"
 	"github.com/fsnotify/fsnotify"
@@ -32,6 +36,8 @@ type App struct {
 	done       chan struct{} // 用于结束ticker goroutine的信号
 	cron       *cron.Cron    // crontab调度器
 	version    string
+	runLock    sync.Mutex     // 完整检测和快速复查互斥
+	published  []check.Result // 最近一次发布的节点，供快速复查使用
 }
 
 // New 创建新的应用实例
@@ -102,7 +108,7 @@ func (app *App) Initialize() error {
 	monitor.StartMemoryMonitor()
 
 	// 设置信号处理器
-	utils.SetupSignalHandler(&check.ForceClose)
+	utils.SetupSignalHandler(check.Cancel)
 	return nil
 }
 
@@ -129,6 +135,8 @@ func (app *App) Run() {
 		app.triggerCheck()
 	}
 
+	go app.runQuickCheck()
+
 	// 在主循环中处理手动触发
 	for range app.checkChan {
 		go app.triggerCheck()
@@ -224,6 +232,10 @@ func (app *App) triggerCheck() {
 	}
 	defer app.checking.Store(false)
 
+	// 等待正在进行的快速复查结束
+	app.runLock.Lock()
+	defer app.runLock.Unlock()
+
 	if err := app.checkProxies(); err != nil {
 		slog.Error(fmt.Sprintf("检测代理失败: %v", err))
 		os.Exit(1)
@@ -250,8 +262,9 @@ func (app *App) triggerCheck() {
 func (app *App) checkProxies() error {
 	slog.Info("开始准备检测代理", "进度展示", config.GlobalConfig.PrintProgress)
 
-	results, err := check.Check()
-	if err != nil {
+	results, err := check.Check(context.Background())
+	cancelled := errors.Is(err, check.ErrCancelled)
+	if err != nil && !cancelled {
 		return fmt.Errorf("检测代理失败: %w", err)
 	}
 	slog.Info("检测完成，开始保存", "resultCount", len(results))
@@ -264,8 +277,14 @@ func (app *App) checkProxies() error {
 		}
 	}
 
+	if cancelled && !config.GlobalConfig.SavePartialOnClose {
+		slog.Warn("检测被强制结束，不保存本次部分结果", "resultCount", len(results))
+		return nil
+	}
+
 	slog.Info("检测完成")
 	save.SaveConfig(results)
+	app.published = results
 	slog.Info("保存完成")
 	utils.SendNotify(len(results))
 	utils.UpdateSubs()
@@ -276,6 +295,74 @@ func (app *App) checkProxies() error {
 	return nil
 }
 
+// runQuickCheck 按 quick-check-interval 定期复查已发布的节点，配置修改后下一轮生效
+func (app *App) runQuickCheck() {
+	for {
+		interval := config.GlobalConfig.QuickCheckInterval
+		if interval <= 0 || config.GlobalConfig.ManualTriggerOnly {
+			time.Sleep(time.Minute)
+			continue
+		}
+		time.Sleep(time.Duration(interval) * time.Minute)
+		app.quickCheck()
+	}
+}
+
+// quickCheck 只对已发布的节点做存活检测，有节点失效时重新保存
+// 完整检测进行中时跳过
+func (app *App) quickCheck() {
+	if app.checking.Load() || !app.runLock.TryLock() {
+		slog.Debug("完整检测进行中，跳过快速复查")
+		return
+	}
+	defer app.runLock.Unlock()
+
+	if len(app.published) == 0 {
+		return
+	}
+	slog.Info("开始快速复查已发布节点", "count", len(app.published))
+	alive, err := check.QuickCheck(context.Background(), app.published)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("快速复查未完成，不更新已发布节点: %v", err))
+		return
+	}
+	if len(alive) == len(app.published) {
+		slog.Info("快速复查完成，节点全部可用")
+		return
+	}
+	slog.Info(fmt.Sprintf("快速复查完成，移除失效节点: %d，剩余: %d", len(app.published)-len(alive), len(alive)))
+	if len(alive) == 0 {
+		// 大概率是本机网络问题，保留原来的节点
+		slog.Warn("快速复查全部失败，不更新已发布节点")
+		return
+	}
+	save.SaveConfig(alive)
+	pruneGlobalProxies(app.published, alive)
+	app.published = alive
+	utils.UpdateSubs()
+}
+
+// pruneGlobalProxies 从保留的成功节点中移除快速复查失效的节点，避免下一轮完整检测又加回来
+func pruneGlobalProxies(published, alive []check.Result) {
+	if len(config.GlobalProxies) == 0 {
+		return
+	}
+	dead := make(map[string]bool)
+	for _, res := range published {
+		dead[proxyutils.Fingerprint(res.Proxy)] = true
+	}
+	for _, res := range alive {
+		delete(dead, proxyutils.Fingerprint(res.Proxy))
+	}
+	kept := config.GlobalProxies[:0]
+	for _, proxy := range config.GlobalProxies {
+		if !dead[proxyutils.Fingerprint(proxy)] {
+			kept = append(kept, proxy)
+		}
+	}
+	config.GlobalProxies = kept
+}
+
 func TempLog() string {
 	return filepath.Join(os.TempDir(), "subs-check.log")
 }
@@ -12,6 +12,7 @@ import (
 
 	"github.com/beck-8/subs-check/check"
 	"github.com/beck-8/subs-check/config"
+	proxyutils "github.com/beck-8/subs-check/proxy"
 	"github.com/beck-8/subs-check/save/method"
 	"github.com/gin-gonic/gin"
 	"gopkg.in/yaml.v3"
@@ -66,6 +67,8 @@ func (app *App) initHttpServer() error {
 			api.GET("/status", app.getStatus)
 			api.POST("/trigger-check", app.triggerCheckHandler)
 			api.POST("/force-close", app.forceCloseHandler)
+			api.GET("/sub-health", app.getSubHealth)
+			api.GET("/report", app.getReport)
 			// 版本相关API
 			api.GET("/version", app.getVersion)
 
@@ -166,10 +169,20 @@ func (app *App) triggerCheckHandler(c *gin.Context) {
 
 // forceCloseHandler 强制关闭
 func (app *App) forceCloseHandler(c *gin.Context) {
-	check.ForceClose.Store(true)
+	check.Cancel()
 	c.JSON(http.StatusOK, gin.H{"message": "已强制关闭"})
 }
 
+// getSubHealth 获取订阅健康状况
+func (app *App) getSubHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"subs": proxyutils.SubHealthList()})
+}
+
+// getReport 获取最近一次检测报告
+func (app *App) getReport(c *gin.Context) {
+	c.JSON(http.StatusOK, check.LastReport())
+}
+
 // getLogs 获取最近日志
 func (app *App) getLogs(c *gin.Context) {
 	// 简单实现，从日志文件读取最后xx行
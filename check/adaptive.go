@@ -0,0 +1,265 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+	human "github.com/docker/go-units"
+)
+
+// 自适应并发：每个阶段按最大并发启动线程，由 gate 限制同时工作的线程数，
+// 控制器定期根据失败率、超时率、总流量和内存使用调整各阶段的上限
+
+const (
+	adaptiveInterval   = 5 * time.Second
+	adaptiveMinSamples = 5 // 一个周期内完成的节点少于这个数时不根据失败率调整
+)
+
+type stage int
+
+const (
+	stageAlive stage = iota
+	stageSpeed
+	stageMedia
+	stageCount
+)
+
+var stageNames = [stageCount]string{"alive", "speed", "media"}
+
+// liveBytes 实时流量统计，用于计算测速阶段的总吞吐
+var liveBytes atomic.Uint64
+
+// gate 可以动态调整上限的信号量，nil 表示不限制
+type gate struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	limit   int
+	active  int
+	waiting int
+}
+
+func newGate(limit int) *gate {
+	g := &gate{limit: limit}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+func (g *gate) acquire() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	g.waiting++
+	for g.active >= g.limit {
+		g.cond.Wait()
+	}
+	g.waiting--
+	g.active++
+	g.mu.Unlock()
+}
+
+func (g *gate) release() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	g.active--
+	g.mu.Unlock()
+	g.cond.Signal()
+}
+
+func (g *gate) setLimit(n int) {
+	g.mu.Lock()
+	g.limit = n
+	g.mu.Unlock()
+	g.cond.Broadcast()
+}
+
+// snapshot 返回当前上限，以及是否有节点在排队等待
+func (g *gate) snapshot() (limit int, busy bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.limit, g.waiting > 0
+}
+
+type stageStats struct {
+	done     atomic.Int64
+	failed   atomic.Int64
+	timeouts atomic.Int64
+}
+
+// adaptiveController 自适应并发控制器
+type adaptiveController struct {
+	gates     [stageCount]*gate
+	stats     [stageCount]stageStats
+	min       [stageCount]int
+	max       [stageCount]int
+	lastRate  [stageCount]float64 // 上个周期的失败率
+	raised    [stageCount]bool    // 上一次调整是否为增加
+	memLimit  uint64
+	lastBytes uint64
+}
+
+// newAdaptiveController 以各阶段配置的并发数作为初始值创建控制器
+func newAdaptiveController(start [stageCount]int, proxyCount int) *adaptiveController {
+	c := &adaptiveController{memLimit: adaptiveMemoryLimit()}
+	for st := range stageCount {
+		lo := max(config.GlobalConfig.MinConcurrent, 1)
+		hi := config.GlobalConfig.MaxConcurrent
+		if hi <= 0 {
+			hi = start[st] * 4
+		}
+		hi = max(min(hi, proxyCount), 1)
+		lo = min(lo, hi)
+		c.min[st], c.max[st] = lo, hi
+		c.gates[st] = newGate(min(max(start[st], lo), hi))
+	}
+	c.lastBytes = liveBytes.Load()
+	return c
+}
+
+// adaptiveMemoryLimit 读取 adaptive-memory-limit，未配置时取 SUB_CHECK_MEM_LIMIT 的 80%，避免触发重启
+func adaptiveMemoryLimit() uint64 {
+	if limit := config.GlobalConfig.AdaptiveMemoryLimit; limit != "" {
+		n, err := human.FromHumanSize(limit)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("adaptive-memory-limit 格式错误: %v", err))
+			return 0
+		}
+		return uint64(n)
+	}
+	if limit := os.Getenv("SUB_CHECK_MEM_LIMIT"); limit != "" {
+		if n, err := human.FromHumanSize(limit); err == nil {
+			return uint64(n) / 10 * 8
+		}
+	}
+	return 0
+}
+
+func (c *adaptiveController) gate(st stage) *gate {
+	if c == nil {
+		return nil
+	}
+	return c.gates[st]
+}
+
+// workers 返回某个阶段需要启动的线程数，开启自适应时按最大并发启动，实际同时工作的数量由 gate 限制
+func (c *adaptiveController) workers(st stage, threads int) int {
+	if c == nil {
+		return threads
+	}
+	return c.max[st]
+}
+
+// observe 记录一个节点在某个阶段的检测结果
+func (c *adaptiveController) observe(st stage, ok, timeout bool) {
+	if c == nil {
+		return
+	}
+	s := &c.stats[st]
+	s.done.Add(1)
+	if !ok {
+		s.failed.Add(1)
+	}
+	if timeout {
+		s.timeouts.Add(1)
+	}
+}
+
+// run 定期调整并发，直到 stop 关闭
+func (c *adaptiveController) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(adaptiveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.adjust()
+		}
+	}
+}
+
+func (c *adaptiveController) adjust() {
+	memHigh, memFull := c.memoryPressure()
+
+	bytes := liveBytes.Load()
+	throughput := float64(bytes-c.lastBytes) / adaptiveInterval.Seconds()
+	c.lastBytes = bytes
+	bandwidth := float64(config.GlobalConfig.TotalSpeedLimit) * 1024 * 1024
+
+	for st := range stageCount {
+		limit, busy := c.gates[st].snapshot()
+		s := &c.stats[st]
+		done, failed, timeouts := s.done.Swap(0), s.failed.Swap(0), s.timeouts.Swap(0)
+
+		var failRate, timeoutRate float64
+		if done > 0 {
+			failRate = float64(failed) / float64(done)
+			timeoutRate = float64(timeouts) / float64(done)
+		}
+		step := max(limit/4, 1)
+		next, reason := limit, ""
+
+		switch {
+		case memFull:
+			next, reason = limit/2, "内存接近上限"
+		case st == stageSpeed && bandwidth > 0 && throughput >= bandwidth*0.9:
+			next, reason = limit-step, "总带宽已满"
+		case done < adaptiveMinSamples:
+		case c.raised[st] && failRate > c.lastRate[st]+0.1:
+			next, reason = limit-step, "增加并发后失败率上升"
+		case timeoutRate > 0.5:
+			next, reason = limit-step, "超时过多"
+		}
+		if reason == "" && busy && !memHigh &&
+			(st != stageSpeed || bandwidth == 0 || throughput < bandwidth*0.7) {
+			next, reason = limit+step, "队列积压"
+		}
+		if done >= adaptiveMinSamples {
+			c.lastRate[st] = failRate
+		}
+
+		next = min(max(next, c.min[st]), c.max[st])
+		c.raised[st] = next > limit
+		if next == limit {
+			continue
+		}
+		c.gates[st].setLimit(next)
+		slog.Debug(fmt.Sprintf("自适应并发调整: %s %d -> %d", stageNames[st], limit, next),
+			"原因", reason, "失败率", fmt.Sprintf("%.2f", failRate), "超时率", fmt.Sprintf("%.2f", timeoutRate),
+			"吞吐", fmt.Sprintf("%.2fMB/s", throughput/1024/1024))
+	}
+}
+
+// memoryPressure 返回内存是否超过上限的 80% 和是否超过上限
+func (c *adaptiveController) memoryPressure() (high, full bool) {
+	if c.memLimit == 0 {
+		return false, false
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	usage := m.HeapAlloc + m.StackInuse
+	return usage > c.memLimit/10*8, usage > c.memLimit
+}
+
+// isTimeout 判断错误是否为超时
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
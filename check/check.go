@@ -2,17 +2,18 @@ package check
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math"
 	"net"
 	"net/http"
-	"os"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/beck-8/subs-check/check/platform"
@@ -36,19 +37,54 @@ type Result struct {
 	Gemini     bool
 	TikTok     string
 	IP         string
-	IPRisk     string
+	IPRisk     string // 展示用的风险分数，如 35%
+	RiskScore  int    // 归一化后的风险分数 0-100，-1 为未检测
+	RiskType   string // residential/datacenter/proxy/abuse
+	IPType     string // 出口类型 residential/mobile/hosting
+	EntryIP    string // 入口IP，即配置的服务器地址解析结果
+	EntryCode  string // 入口国家代码
+	ClaimCode  string // 节点原名中声称的国家代码
 	Country    string
+	Region     string
+	City       string
+	Lat        float64
+	Lon        float64
+	ASN        string
+	ISP        string
+	Latency    int // 延迟测试耗时(ms)
+	UDP        bool
+	UDPLatency int // UDP 往返耗时(ms)
+	Speed      int // 下载速度(KB/s)，预热后的平均值
+	PeakSpeed  int // 下载峰值速度(KB/s)
+	Upload     int // 上传速度(KB/s)
+	Index      int // 重命名分配的序号，未重命名为 0
 }
 
 // ProxyChecker 处理代理检测的主要结构体
 type ProxyChecker struct {
-	results     []Result
-	proxyCount  int
-	threadCount int
-	progress    int32
-	available   int32
-	resultChan  chan Result
-	tasks       chan map[string]any
+	results      []Result
+	resultLock   sync.Mutex
+	startTime    time.Time
+	proxyCount   int
+	aliveThreads int
+	speedThreads int
+	mediaThreads int
+	progress     int32
+	available    int32
+	resultChan   chan Result
+	tasks        chan map[string]any
+	speedTasks   chan *Result // 存活检测通过，等待测速
+	mediaTasks   chan *Result // 等待流媒体等检测
+	subTested    map[string]int
+	tested       map[string]bool // 已完成检测的节点，用于保存进度
+	resumed      map[string]bool // 上次中断前已完成检测的节点
+	quota        *quota
+	exitIPs      *exitIPDedup // 出口IP去重，未开启时为空
+	admitLock    sync.Mutex   // 去重、配额和重命名需要一起完成
+	subLock      sync.Mutex
+	ctx          context.Context     // 整轮检测的 context，强制关闭时取消
+	nameTmpl     *template.Template  // 命名模板，为空时使用默认命名
+	adaptive     *adaptiveController // 自适应并发，未开启时为空
 }
 
 var Progress atomic.Uint32
@@ -56,31 +92,79 @@ var Available atomic.Uint32
 var ProxyCount atomic.Uint32
 var TotalBytes atomic.Uint64
 
-var ForceClose atomic.Bool
-
 var Bucket *ratelimit.Bucket
 
-// NewProxyChecker 创建新的检测器实例
-func NewProxyChecker(proxyCount int) *ProxyChecker {
-	threadCount := config.GlobalConfig.Concurrent
-	if proxyCount < threadCount {
-		threadCount = proxyCount
+// ErrCancelled 检测被强制关闭，返回的结果只包含已完成检测的节点
+var ErrCancelled = errors.New("检测已取消")
+
+var (
+	runCancel context.CancelFunc
+	runLock   sync.Mutex
+)
+
+// Cancel 强制结束当前检测，正在进行的拨号、测速和平台检测会立即中止
+func Cancel() {
+	runLock.Lock()
+	defer runLock.Unlock()
+	if runCancel != nil {
+		runCancel()
 	}
+}
 
+func setRunCancel(cancel context.CancelFunc) {
+	runLock.Lock()
+	defer runLock.Unlock()
+	runCancel = cancel
+}
+
+// NewProxyChecker 创建新的检测器实例
+func NewProxyChecker(proxyCount int) *ProxyChecker {
 	ProxyCount.Store(uint32(proxyCount))
-	return &ProxyChecker{
-		results:     make([]Result, 0),
-		proxyCount:  proxyCount,
-		threadCount: threadCount,
-		resultChan:  make(chan Result),
-		tasks:       make(chan map[string]any, 1),
+	pc := &ProxyChecker{
+		results:      make([]Result, 0),
+		startTime:    time.Now(),
+		proxyCount:   proxyCount,
+		aliveThreads: stageThreads(config.GlobalConfig.AliveConcurrent, proxyCount),
+		speedThreads: stageThreads(config.GlobalConfig.SpeedConcurrent, proxyCount),
+		mediaThreads: stageThreads(config.GlobalConfig.MediaConcurrent, proxyCount),
+		resultChan:   make(chan Result),
+		tasks:        make(chan map[string]any, 1),
+		// 后续阶段的队列能容纳全部节点，前面的阶段不会被慢节点阻塞
+		speedTasks: make(chan *Result, proxyCount),
+		mediaTasks: make(chan *Result, proxyCount),
+		subTested:  make(map[string]int),
+		tested:     make(map[string]bool),
+		quota:      newQuota(),
+		exitIPs:    newExitIPDedup(config.GlobalConfig.ExitIPDedup),
+		nameTmpl:   parseNameTemplate(),
+	}
+	if config.GlobalConfig.AdaptiveConcurrency && proxyCount > 0 {
+		pc.adaptive = newAdaptiveController([stageCount]int{pc.aliveThreads, pc.speedThreads, pc.mediaThreads}, proxyCount)
+	}
+	return pc
+}
+
+// stageThreads 返回某个阶段的并发数，未配置时使用 concurrent，且不超过节点数量
+func stageThreads(n, proxyCount int) int {
+	if n <= 0 {
+		n = config.GlobalConfig.Concurrent
 	}
+	return min(n, proxyCount)
 }
 
 // Check 执行代理检测的主函数
-func Check() ([]Result, error) {
+// 被 Cancel 强制关闭时返回已完成检测的节点和 ErrCancelled
+func Check(ctx context.Context) ([]Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	setRunCancel(cancel)
+	defer setRunCancel(nil)
+
 	proxyutils.ResetRenameCounter()
-	ForceClose.Store(false)
+	proxyutils.PrepareMMDB()
+	proxyutils.PrepareIPCache()
+	proxyutils.ResetEntryGeo()
+	platform.LoadRiskBlocklist()
 
 	ProxyCount.Store(0)
 	Available.Store(0)
@@ -88,6 +172,8 @@ func Check() ([]Result, error) {
 
 	TotalBytes.Store(0)
 
+	report := Report{StartTime: time.Now()}
+
 	// 之前好的节点前置
 	var proxies []map[string]any
 	if config.GlobalConfig.KeepSuccessProxies {
@@ -100,19 +186,54 @@ func Check() ([]Result, error) {
 	}
 	proxies = append(proxies, tmp...)
 	slog.Info(fmt.Sprintf("获取节点数量: %d", len(proxies)))
+	if ctx.Err() != nil {
+		return nil, ErrCancelled
+	}
 
 	// 重置全局节点
 	config.GlobalProxies = make([]map[string]any, 0)
 
-	proxies = proxyutils.DeduplicateProxies(proxies)
+	report.Fetched = len(proxies)
+
+	proxies, report.Dedup = proxyutils.DeduplicateProxies(proxies)
 	slog.Info(fmt.Sprintf("去重后节点数量: %d", len(proxies)))
 
+	if config.GlobalConfig.DNSDedup {
+		proxies, report.DNSMerged = proxyutils.DeduplicateByDNS(proxies)
+		slog.Info(fmt.Sprintf("DNS去重后节点数量: %d", len(proxies)), "合并", report.DNSMerged)
+	}
+
+	var history map[string]*nodeHistory
+	if config.GlobalConfig.PriorityOrder {
+		history = loadNodeHistory()
+		proxies = prioritize(proxies, history)
+	}
+
 	checker := NewProxyChecker(len(proxies))
-	return checker.run(proxies)
+	checker.resume(loadCheckpoint())
+	results, err := checker.run(ctx, proxies)
+	if history != nil {
+		checker.updateNodeHistory(history, proxies)
+	}
+
+	report.ExitGroups = checker.exitIPs.groups()
+
+	proxyutils.SaveRenameNumbers()
+	proxyutils.SaveIPCache()
+
+	report.Available = len(results)
+	report.Cancelled = ctx.Err() != nil
+	report.EndTime = time.Now()
+	setLastReport(report)
+	if err == nil && report.Cancelled {
+		err = ErrCancelled
+	}
+	return results, err
 }
 
 // Run 运行检测流程
-func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
+func (pc *ProxyChecker) run(ctx context.Context, proxies []map[string]any) ([]Result, error) {
+	pc.ctx = ctx
 	if config.GlobalConfig.TotalSpeedLimit != 0 {
 		Bucket = ratelimit.NewBucketWithRate(float64(config.GlobalConfig.TotalSpeedLimit*1024*1024), int64(config.GlobalConfig.TotalSpeedLimit*1024*1024/10))
 	} else {
@@ -120,17 +241,35 @@ func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
 	}
 
 	slog.Info("开始检测节点")
-	slog.Info("当前参数", "timeout", config.GlobalConfig.Timeout, "concurrent", config.GlobalConfig.Concurrent, "enable-speedtest", config.GlobalConfig.SpeedTestUrl != "", "min-speed", config.GlobalConfig.MinSpeed, "download-timeout", config.GlobalConfig.DownloadTimeout, "download-mb", config.GlobalConfig.DownloadMB, "total-speed-limit", config.GlobalConfig.TotalSpeedLimit)
+	slog.Info("当前参数", "timeout", config.GlobalConfig.Timeout, "concurrent", config.GlobalConfig.Concurrent, "alive-concurrent", pc.aliveThreads, "speed-concurrent", pc.speedThreads, "media-concurrent", pc.mediaThreads, "adaptive-concurrency", pc.adaptive != nil, "enable-speedtest", speedTestEnabled(), "min-speed", config.GlobalConfig.MinSpeed, "download-timeout", config.GlobalConfig.DownloadTimeout, "download-mb", config.GlobalConfig.DownloadMB, "total-speed-limit", config.GlobalConfig.TotalSpeedLimit)
 
 	done := make(chan bool)
 	if config.GlobalConfig.PrintProgress {
 		go pc.showProgress(done)
 	}
+	// 三个阶段同时运行，某个阶段的线程全部退出后关闭下一阶段的队列
 	var wg sync.WaitGroup
-	// 启动工作线程
-	for i := 0; i < pc.threadCount; i++ {
-		wg.Add(1)
-		go pc.worker(&wg)
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		runStage(pc.adaptive.workers(stageAlive, pc.aliveThreads), pc.aliveWorker)
+		close(pc.speedTasks)
+	}()
+	go func() {
+		defer wg.Done()
+		runStage(pc.adaptive.workers(stageSpeed, pc.speedThreads), pc.speedWorker)
+		close(pc.mediaTasks)
+	}()
+	go func() {
+		defer wg.Done()
+		runStage(pc.adaptive.workers(stageMedia, pc.mediaThreads), pc.mediaWorker)
+	}()
+	stopBackground := make(chan struct{})
+	if pc.adaptive != nil {
+		go pc.adaptive.run(stopBackground)
+	}
+	if checkpointEnabled() {
+		go pc.checkpointLoop(stopBackground)
 	}
 
 	// 发送任务
@@ -148,12 +287,21 @@ func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
 
 	slog.Info("等待所有检测线程完成")
 	wg.Wait()
+	close(stopBackground)
 	slog.Info("所有检测线程完成，准备关闭结果通道")
 	close(pc.resultChan)
 
 	// 等待结果收集完成
 	collectWg.Wait()
 	slog.Info("结果收集完成", "totalResults", len(pc.results))
+	if checkpointEnabled() {
+		// 被强制关闭时保留进度，下次检测从中断处继续
+		if ctx.Err() != nil {
+			pc.saveCheckpoint()
+		} else {
+			removeCheckpoint()
+		}
+	}
 	// 等待进度条显示完成
 	time.Sleep(100 * time.Millisecond)
 
@@ -165,6 +313,9 @@ func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
 		slog.Warn(fmt.Sprintf("达到节点数量限制: %d", config.GlobalConfig.SuccessLimit))
 	}
 	slog.Info(fmt.Sprintf("可用节点数量: %d", len(pc.results)))
+	if n := len(pc.quota.overQuota); n > 0 {
+		slog.Info(fmt.Sprintf("超出配额丢弃的节点数量: %d", n))
+	}
 	slog.Info(fmt.Sprintf("测试总消耗流量: %.3fGB", float64(TotalBytes.Load())/1024/1024/1024))
 
 	// 检查订阅成功率并发出警告
@@ -173,134 +324,26 @@ func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
 	return pc.results, nil
 }
 
-// worker 处理单个代理检测的工作线程
-func (pc *ProxyChecker) worker(wg *sync.WaitGroup) {
-	defer wg.Done()
-	for proxy := range pc.tasks {
-		ctx, cancel := context.WithTimeout(context.Background(), pc.proxyTimeout())
-		result := pc.checkProxy(ctx, proxy)
-		cancel()
-		if result != nil {
-			pc.resultChan <- *result
-		}
-		pc.incrementProgress()
-	}
-}
-
-// checkProxy 检测单个代理
-func (pc *ProxyChecker) checkProxy(ctx context.Context, proxy map[string]any) *Result {
-	select {
-	case <-ctx.Done():
-		slog.Warn("节点检测超时/取消，跳过", "name", proxy["name"])
-		return nil
-	default:
-	}
-
-	res := &Result{
-		Proxy: proxy,
-	}
-
-	if os.Getenv("SUB_CHECK_SKIP") != "" {
-		// slog.Debug(fmt.Sprintf("跳过检测代理: %v", proxy["name"]))
-		return res
-	}
-
-	httpClient := CreateClient(ctx, proxy)
-	if httpClient == nil {
-		slog.Debug(fmt.Sprintf("创建代理Client失败: %v", proxy["name"]))
-		return nil
-	}
-	defer httpClient.Close()
-
-	select {
-	case <-ctx.Done():
-		return nil
-	default:
-	}
-
-	google, err := platform.CheckAlive(ctx, httpClient.Client)
-	if err != nil || !google {
-		return nil
-	}
-
-	var speed int
-	if config.GlobalConfig.SpeedTestUrl != "" {
-		select {
-		case <-ctx.Done():
-			slog.Warn("节点检测超时/取消，测速前退出", "name", proxy["name"])
-			return nil
-		default:
-		}
-		speed, _, err = platform.CheckSpeed(ctx, httpClient.Client, Bucket, httpClient.BytesRead)
-		if err != nil || speed < config.GlobalConfig.MinSpeed {
-			return nil
-		}
+// updateProxyName 更新代理名称
+func (pc *ProxyChecker) updateProxyName(res *Result, speed int) {
+	// 序号只分配一次，模板渲染失败时默认命名沿用同一个序号
+	if pc.nameTmpl != nil || config.GlobalConfig.RenameNode {
+		res.Index = proxyutils.NextIndex(strings.ToUpper(res.Country), res.Proxy)
 	}
 
-	if config.GlobalConfig.MediaCheck {
-		// 遍历需要检测的平台
-		for _, plat := range config.GlobalConfig.Platforms {
-			switch plat {
-			case "openai":
-				cookiesOK, clientOK := platform.CheckOpenAI(httpClient.Client)
-				if clientOK && cookiesOK {
-					res.Openai = true
-				} else if cookiesOK || clientOK {
-					res.OpenaiWeb = true
-				}
-			case "youtube":
-				if region, _ := platform.CheckYoutube(httpClient.Client); region != "" {
-					res.Youtube = region
-				}
-			case "netflix":
-				if ok, _ := platform.CheckNetflix(httpClient.Client); ok {
-					res.Netflix = true
-				}
-			case "disney":
-				if ok, _ := platform.CheckDisney(httpClient.Client); ok {
-					res.Disney = true
-				}
-			case "gemini":
-				if ok, _ := platform.CheckGemini(httpClient.Client); ok {
-					res.Gemini = true
-				}
-			case "iprisk":
-				country, ip := proxyutils.GetProxyCountry(httpClient.Client)
-				if ip == "" {
-					break
-				}
-				res.IP = ip
-				res.Country = country
-				risk, err := platform.CheckIPRisk(httpClient.Client, ip)
-				if err == nil {
-					res.IPRisk = risk
-				} else {
-					// 失败的可能性高，所以放上日志
-					slog.Debug(fmt.Sprintf("查询IP风险失败: %v", err))
-				}
-			case "tiktok":
-				if region, _ := platform.CheckTikTok(httpClient.Client); region != "" {
-					res.TikTok = region
-				}
-			}
+	// 配置了命名模板时完全按模板生成
+	if pc.nameTmpl != nil {
+		name, err := renderName(pc.nameTmpl, res, res.Index)
+		if err == nil {
+			res.Proxy["name"] = name
+			return
 		}
+		slog.Debug(fmt.Sprintf("命名模板渲染失败，使用默认命名: %v", err))
 	}
-	// 更新代理名称
-	pc.updateProxyName(res, httpClient, speed)
-	pc.incrementAvailable()
-	return res
-}
 
-// updateProxyName 更新代理名称
-func (pc *ProxyChecker) updateProxyName(res *Result, httpClient *ProxyClient, speed int) {
 	// 以节点IP查询位置重命名节点
 	if config.GlobalConfig.RenameNode {
-		if res.Country != "" {
-			res.Proxy["name"] = config.GlobalConfig.NodePrefix + proxyutils.Rename(res.Country)
-		} else {
-			country, _ := proxyutils.GetProxyCountry(httpClient.Client)
-			res.Proxy["name"] = config.GlobalConfig.NodePrefix + proxyutils.Rename(country)
-		}
+		res.Proxy["name"] = config.GlobalConfig.NodePrefix + proxyutils.Rename(res.Country, res.Index)
 	}
 
 	name := res.Proxy["name"].(string)
@@ -308,15 +351,13 @@ func (pc *ProxyChecker) updateProxyName(res *Result, httpClient *ProxyClient, sp
 
 	var tags []string
 	// 获取速度
-	if config.GlobalConfig.SpeedTestUrl != "" {
+	if speedTestEnabled() {
 		name = regexp.MustCompile(`\s*\|(?:\s*[\d.]+[KM]B/s)`).ReplaceAllString(name, "")
-		var speedStr string
-		if speed < 1024 {
-			speedStr = fmt.Sprintf("%dKB/s", speed)
-		} else {
-			speedStr = fmt.Sprintf("%.1fMB/s", float64(speed)/1024)
-		}
-		tags = append(tags, speedStr)
+		tags = append(tags, formatSpeed(speed))
+	}
+	if config.GlobalConfig.UploadTestUrl != "" {
+		name = regexp.MustCompile(`\s*\|(?:\s*↑[\d.]+[KM]B/s)`).ReplaceAllString(name, "")
+		tags = append(tags, "↑"+formatSpeed(res.Upload))
 	}
 
 	if config.GlobalConfig.MediaCheck {
@@ -325,41 +366,27 @@ func (pc *ProxyChecker) updateProxyName(res *Result, httpClient *ProxyClient, sp
 	}
 
 	// 按用户输入顺序定义
-	for _, plat := range config.GlobalConfig.Platforms {
-		switch plat {
-		case "openai":
-			if res.Openai {
-				tags = append(tags, "GPT⁺")
-			} else if res.OpenaiWeb {
-				tags = append(tags, "GPT")
-			}
-		case "netflix":
-			if res.Netflix {
-				tags = append(tags, "NF")
-			}
-		case "disney":
-			if res.Disney {
-				tags = append(tags, "D+")
-			}
-		case "gemini":
-			if res.Gemini {
-				tags = append(tags, "GM")
-			}
-		case "iprisk":
-			if res.IPRisk != "" {
-				tags = append(tags, res.IPRisk)
-			}
-		case "youtube":
-			if res.Youtube != "" {
-				tags = append(tags, fmt.Sprintf("YT-%s", res.Youtube))
-			}
-		case "tiktok":
-			if res.TikTok != "" {
-				tags = append(tags, fmt.Sprintf("TK-%s", res.TikTok))
-			}
+	tags = append(tags, platformTags(res)...)
+
+	if config.GlobalConfig.UDPCheck {
+		name = regexp.MustCompile(`\s*\|UDP\b`).ReplaceAllString(name, "")
+		if res.UDP {
+			tags = append(tags, "UDP")
 		}
 	}
 
+	if config.GlobalConfig.IPTypeTag {
+		name = regexp.MustCompile(`\s*\|(?:Res|Mob|DC)\b`).ReplaceAllString(name, "")
+		if tag := ipTypeTags[res.IPType]; tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	if config.GlobalConfig.GeoMismatchTag {
+		name = regexp.MustCompile(`\s*\|(?:Relay-[A-Z]{2}|Mismatch)`).ReplaceAllString(name, "")
+		tags = append(tags, mismatchTags(res)...)
+	}
+
 	if tag, ok := res.Proxy["sub_tag"].(string); ok && tag != "" {
 		tags = append(tags, tag)
 	}
@@ -373,6 +400,135 @@ func (pc *ProxyChecker) updateProxyName(res *Result, httpClient *ProxyClient, sp
 
 }
 
+// platformTags 按 platforms 配置顺序生成解锁标记
+func platformTags(res *Result) []string {
+	var tags []string
+	for _, plat := range config.GlobalConfig.Platforms {
+		if tag := platformTag(res, plat); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// platformTag 返回单个平台的解锁标记，未解锁返回空
+func platformTag(res *Result, plat string) string {
+	switch plat {
+	case "openai":
+		if res.Openai {
+			return "GPT⁺"
+		} else if res.OpenaiWeb {
+			return "GPT"
+		}
+	case "netflix":
+		if res.Netflix {
+			return "NF"
+		}
+	case "disney":
+		if res.Disney {
+			return "D+"
+		}
+	case "gemini":
+		if res.Gemini {
+			return "GM"
+		}
+	case "iprisk":
+		return res.IPRisk
+	case "youtube":
+		if res.Youtube != "" {
+			return fmt.Sprintf("YT-%s", res.Youtube)
+		}
+	case "tiktok":
+		if res.TikTok != "" {
+			return fmt.Sprintf("TK-%s", res.TikTok)
+		}
+	}
+	return ""
+}
+
+func formatSpeed(speed int) string {
+	if speed < 1024 {
+		return fmt.Sprintf("%dKB/s", speed)
+	}
+	return fmt.Sprintf("%.1fMB/s", float64(speed)/1024)
+}
+
+// mismatchTags 入口与出口国家不同时标记 Relay-入口国家，原名声称的国家与出口不符时标记 Mismatch
+func mismatchTags(res *Result) []string {
+	if res.Country == "" {
+		return nil
+	}
+	var tags []string
+	if res.EntryCode != "" && !strings.EqualFold(res.EntryCode, res.Country) {
+		tags = append(tags, "Relay-"+strings.ToUpper(res.EntryCode))
+	}
+	if res.ClaimCode != "" && !strings.EqualFold(res.ClaimCode, res.Country) {
+		tags = append(tags, "Mismatch")
+	}
+	return tags
+}
+
+// speedTestEnabled 是否配置了测速地址
+func speedTestEnabled() bool {
+	return len(platform.SpeedTestUrls()) > 0
+}
+
+// needGeo 是否有功能依赖出口IP位置信息
+func needGeo() bool {
+	if config.GlobalConfig.RenameNode || config.GlobalConfig.NameTemplate != "" ||
+		config.GlobalConfig.ExitIPDedup > 0 || config.GlobalConfig.IPTypeTag || config.GlobalConfig.GeoMismatchTag ||
+		config.GlobalConfig.MaxPerCountry > 0 || len(config.GlobalConfig.MinPerCountry) > 0 {
+		return true
+	}
+	for _, c := range config.GlobalConfig.OutputCategories {
+		if len(c.IPTypes) > 0 || len(c.Countries) > 0 || len(c.Regions) > 0 || len(c.Cities) > 0 || c.GroupBy != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRisk 查询出口IP风险，依次使用离线黑名单、缓存和在线接口
+func (pc *ProxyChecker) checkRisk(ctx context.Context, res *Result, httpClient *http.Client) {
+	if res.IP == "" {
+		geo := proxyutils.GetProxyGeo(httpClient)
+		if geo.IP == "" {
+			return
+		}
+		res.setGeo(geo)
+	}
+
+	risk, ok := platform.CheckBlocklist(res.IP)
+	if !ok {
+		risk, ok = proxyutils.CachedRisk(res.IP)
+	}
+	if !ok {
+		var err error
+		risk, err = platform.CheckIPRisk(ctx, httpClient, res.IP)
+		if err != nil {
+			// 失败的可能性高，所以放上日志
+			slog.Debug(fmt.Sprintf("查询IP风险失败: %v", err))
+			return
+		}
+		proxyutils.CacheRisk(res.IP, risk)
+	}
+	res.RiskScore = risk.Score
+	res.RiskType = risk.Category
+	res.IPRisk = fmt.Sprintf("%d%%", risk.Score)
+}
+
+// setGeo 记录出口IP位置信息
+func (res *Result) setGeo(geo proxyutils.GeoInfo) {
+	res.IP = geo.IP
+	res.Country = geo.Country
+	res.Region = geo.Region
+	res.City = geo.City
+	res.Lat = geo.Lat
+	res.Lon = geo.Lon
+	res.ASN = geo.ASN
+	res.ISP = geo.ISP
+}
+
 // showProgress 显示进度条
 func (pc *ProxyChecker) showProgress(done chan bool) {
 	for {
@@ -413,8 +569,19 @@ func (pc *ProxyChecker) incrementAvailable() {
 	Available.Add(1)
 }
 
+// recordSubTested 记录各订阅实际测试过的节点数，未派发的节点不计入
+func (pc *ProxyChecker) recordSubTested(proxy map[string]any) {
+	subUrl, ok := proxy["sub_url"].(string)
+	if !ok {
+		return
+	}
+	pc.subLock.Lock()
+	pc.subTested[subUrl]++
+	pc.subLock.Unlock()
+}
+
 // proxyTimeout 确保单个节点检测有硬性超时时间
-func (pc *ProxyChecker) proxyTimeout() time.Duration {
+func proxyTimeout() time.Duration {
 	timeout := time.Duration(config.GlobalConfig.Timeout) * time.Millisecond
 	download := time.Duration(config.GlobalConfig.DownloadTimeout) * time.Second
 	if download > timeout {
@@ -429,14 +596,21 @@ func (pc *ProxyChecker) proxyTimeout() time.Duration {
 // distributeProxies 分发代理任务
 func (pc *ProxyChecker) distributeProxies(proxies []map[string]any) {
 	for _, proxy := range proxies {
-		if config.GlobalConfig.SuccessLimit > 0 && atomic.LoadInt32(&pc.available) >= config.GlobalConfig.SuccessLimit {
+		if pc.limitReached() {
 			break
 		}
-		if ForceClose.Load() {
+		if pc.ctx.Err() != nil {
 			slog.Warn("收到强制关闭信号，停止派发任务")
 			break
 		}
-		pc.tasks <- proxy
+		if pc.resumed != nil && pc.resumed[nodeKey(proxy)] {
+			pc.incrementProgress()
+			continue
+		}
+		select {
+		case pc.tasks <- proxy:
+		case <-pc.ctx.Done():
+		}
 	}
 	// // 发送任务结束，进行一次内存回收
 	// for i := range proxies {
@@ -450,7 +624,12 @@ func (pc *ProxyChecker) distributeProxies(proxies []map[string]any) {
 func (pc *ProxyChecker) collectResults() {
 	collected := 0
 	for result := range pc.resultChan {
-		pc.results = append(pc.results, result)
+		pc.resultLock.Lock()
+		if !pc.exitIPs.isEvicted(result.Proxy) {
+			pc.results = append(pc.results, result)
+		}
+		pc.resultLock.Unlock()
+		pc.markTested(result.Proxy)
 		collected++
 	}
 	slog.Info("结果通道关闭", "collected", collected)
@@ -491,6 +670,16 @@ func (pc *ProxyChecker) checkSubscriptionSuccessRate(allProxies []map[string]any
 		}
 	}
 
+	// 更新订阅健康记录
+	healthStats := make(map[string]proxyutils.SubStats, len(subStats))
+	for subUrl, stats := range subStats {
+		healthStats[subUrl] = proxyutils.SubStats{
+			Tested:  pc.subTested[subUrl],
+			Success: stats.success,
+		}
+	}
+	proxyutils.FinishSubHealth(healthStats)
+
 	// 检查成功率并发出警告
 	for subUrl, stats := range subStats {
 		if stats.total > 0 {
@@ -515,8 +704,9 @@ func (pc *ProxyChecker) checkSubscriptionSuccessRate(allProxies []map[string]any
 // statsConn wraps net.Conn to count bytes read and apply rate limiting
 type statsConn struct {
 	net.Conn
-	bytesRead *uint64
-	bucket    *ratelimit.Bucket
+	bytesRead    *uint64
+	bytesWritten *uint64
+	bucket       *ratelimit.Bucket
 }
 
 func (c *statsConn) Read(b []byte) (n int, err error) {
@@ -527,6 +717,20 @@ func (c *statsConn) Read(b []byte) (n int, err error) {
 
 	n, err = c.Conn.Read(b)
 	atomic.AddUint64(c.bytesRead, uint64(n))
+	liveBytes.Add(uint64(n))
+
+	return n, err
+}
+
+func (c *statsConn) Write(b []byte) (n int, err error) {
+	// 上传测速与下载共用同一个限速桶
+	if c.bucket != nil {
+		c.bucket.Wait(int64(len(b)))
+	}
+
+	n, err = c.Conn.Write(b)
+	atomic.AddUint64(c.bytesWritten, uint64(n))
+	liveBytes.Add(uint64(n))
 
 	return n, err
 }
@@ -534,8 +738,9 @@ func (c *statsConn) Read(b []byte) (n int, err error) {
 // CreateClient creates and returns an http.Client with a Close function
 type ProxyClient struct {
 	*http.Client
-	proxy     constant.Proxy
-	BytesRead *uint64
+	proxy        constant.Proxy
+	BytesRead    *uint64
+	BytesWritten *uint64
 }
 
 func CreateClient(ctx context.Context, mapping map[string]any) *ProxyClient {
@@ -545,7 +750,7 @@ func CreateClient(ctx context.Context, mapping map[string]any) *ProxyClient {
 		return nil
 	}
 
-	var bytesRead uint64
+	var bytesRead, bytesWritten uint64
 	overallTimeout := time.Duration(config.GlobalConfig.Timeout) * time.Millisecond
 	if dl := time.Duration(config.GlobalConfig.DownloadTimeout) * time.Second; dl > overallTimeout {
 		overallTimeout = dl
@@ -573,10 +778,13 @@ func CreateClient(ctx context.Context, mapping map[string]any) *ProxyClient {
 			if err != nil {
 				return nil, err
 			}
+			// 超时或整轮检测被取消时立即关闭连接，中止还在进行的请求
+			context.AfterFunc(ctx, func() { conn.Close() })
 			return &statsConn{
-				Conn:      conn,
-				bytesRead: &bytesRead,
-				bucket:    Bucket,
+				Conn:         conn,
+				bytesRead:    &bytesRead,
+				bytesWritten: &bytesWritten,
+				bucket:       Bucket,
 			}, nil
 		},
 		DisableKeepAlives: true,
@@ -587,8 +795,9 @@ func CreateClient(ctx context.Context, mapping map[string]any) *ProxyClient {
 			Timeout:   overallTimeout,
 			Transport: baseTransport,
 		},
-		proxy:     proxy,
-		BytesRead: &bytesRead,
+		proxy:        proxy,
+		BytesRead:    &bytesRead,
+		BytesWritten: &bytesWritten,
 	}
 }
 
@@ -609,4 +818,7 @@ func (pc *ProxyClient) Close() {
 	if pc.BytesRead != nil {
 		TotalBytes.Add(atomic.LoadUint64(pc.BytesRead))
 	}
+	if pc.BytesWritten != nil {
+		TotalBytes.Add(atomic.LoadUint64(pc.BytesWritten))
+	}
 }
@@ -0,0 +1,144 @@
+package check
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+	proxyutils "github.com/beck-8/subs-check/proxy"
+	"github.com/beck-8/subs-check/utils"
+)
+
+const checkpointFile = "check-checkpoint.json"
+
+// checkpoint 检测进度快照，进程中途重启后从这里继续
+type checkpoint struct {
+	StartTime time.Time      `json:"start_time"`
+	SavedAt   time.Time      `json:"saved_at"`
+	Tested    []string       `json:"tested"`     // 已完成检测的节点指纹
+	SubTested map[string]int `json:"sub_tested"` // 各订阅已测试的节点数
+	Results   []Result       `json:"results"`
+}
+
+func checkpointEnabled() bool {
+	return config.GlobalConfig.CheckpointInterval > 0
+}
+
+// nodeKey 节点指纹的摘要，不受重命名影响
+func nodeKey(proxy map[string]any) string {
+	sum := sha1.Sum([]byte(proxyutils.Fingerprint(proxy)))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadCheckpoint 读取上次未完成的检测进度，超过一个检测间隔的进度视为过期，不再恢复
+func loadCheckpoint() *checkpoint {
+	if !checkpointEnabled() {
+		return nil
+	}
+	var cp checkpoint
+	if err := utils.LoadState(checkpointFile, &cp); err != nil {
+		slog.Warn(fmt.Sprintf("加载检测进度失败: %v", err))
+		return nil
+	}
+	if cp.StartTime.IsZero() {
+		return nil
+	}
+	maxAge := time.Duration(max(config.GlobalConfig.CheckInterval, 1)) * time.Minute
+	if time.Since(cp.SavedAt) > maxAge {
+		slog.Info("上次检测进度已过期，重新检测", "saved_at", cp.SavedAt.Format(time.DateTime))
+		removeCheckpoint()
+		return nil
+	}
+	slog.Info(fmt.Sprintf("从上次中断处继续检测，已完成: %d，可用: %d", len(cp.Tested), len(cp.Results)),
+		"start_time", cp.StartTime.Format(time.DateTime))
+	return &cp
+}
+
+// resume 恢复上次的检测结果和计数，已测过的节点在派发时跳过
+func (pc *ProxyChecker) resume(cp *checkpoint) {
+	if cp == nil {
+		return
+	}
+	pc.startTime = cp.StartTime
+	pc.results = append(pc.results, cp.Results...)
+	for i := range cp.Results {
+		res := &cp.Results[i]
+		pc.quota.add(res)
+		pc.exitIPs.restore(res)
+		// 重新登记中断前分配的序号，避免新节点拿到相同的名称
+		if res.Index > 0 {
+			proxyutils.ReserveIndex(strings.ToUpper(res.Country), res.Proxy, res.Index)
+		}
+	}
+	pc.available = int32(len(cp.Results))
+	Available.Add(uint32(len(cp.Results)))
+	pc.resumed = make(map[string]bool, len(cp.Tested))
+	for _, key := range cp.Tested {
+		pc.resumed[key] = true
+		pc.tested[key] = true
+	}
+	for subUrl, n := range cp.SubTested {
+		pc.subTested[subUrl] += n
+	}
+}
+
+// markTested 记录完成检测的节点，用于保存进度和节点历史
+// 被强制关闭丢弃的节点不记录，恢复时会重新检测
+func (pc *ProxyChecker) markTested(proxy map[string]any) {
+	key := nodeKey(proxy)
+	pc.subLock.Lock()
+	pc.tested[key] = true
+	pc.subLock.Unlock()
+}
+
+// checkpointLoop 定期保存检测进度，直到 stop 关闭
+func (pc *ProxyChecker) checkpointLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(config.GlobalConfig.CheckpointInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			pc.saveCheckpoint()
+		}
+	}
+}
+
+func (pc *ProxyChecker) saveCheckpoint() {
+	cp := checkpoint{
+		StartTime: pc.startTime,
+		SavedAt:   time.Now(),
+		SubTested: make(map[string]int),
+	}
+	pc.subLock.Lock()
+	for key := range pc.tested {
+		cp.Tested = append(cp.Tested, key)
+	}
+	for subUrl, n := range pc.subTested {
+		cp.SubTested[subUrl] = n
+	}
+	pc.subLock.Unlock()
+
+	pc.resultLock.Lock()
+	cp.Results = append([]Result(nil), pc.results...)
+	pc.resultLock.Unlock()
+
+	// 在复制结果之后保存序号，保证进度中每个节点的序号都已记录
+	proxyutils.SaveRenameNumbers()
+	if err := utils.SaveState(checkpointFile, cp); err != nil {
+		slog.Warn(fmt.Sprintf("保存检测进度失败: %v", err))
+		return
+	}
+	slog.Debug("已保存检测进度", "tested", len(cp.Tested), "results", len(cp.Results))
+}
+
+func removeCheckpoint() {
+	if err := utils.RemoveState(checkpointFile); err != nil {
+		slog.Warn(fmt.Sprintf("删除检测进度失败: %v", err))
+	}
+}
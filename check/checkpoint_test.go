@@ -0,0 +1,77 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+	proxyutils "github.com/beck-8/subs-check/proxy"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	cfg := *config.GlobalConfig
+	t.Cleanup(func() { *config.GlobalConfig = cfg })
+	config.GlobalConfig.StateDir = t.TempDir()
+	config.GlobalConfig.CheckpointInterval = 60
+	config.GlobalConfig.CheckInterval = 60
+	config.GlobalConfig.RenameNode = true
+	config.GlobalConfig.NameFlag = false
+	config.GlobalConfig.NameLocale = "en"
+	// 宽限期为 0 时序号记录不跨轮保留，只能靠进度中的序号避免重名
+	config.GlobalConfig.RenameGraceHours = 0
+
+	node := func(i int) *Result {
+		return &Result{
+			Proxy:     map[string]any{"name": fmt.Sprintf("node %d", i), "type": "ss", "server": fmt.Sprintf("1.1.1.%d", i), "port": 443, "sub_url": "sub"},
+			Country:   "HK",
+			RiskScore: -1,
+		}
+	}
+
+	// 第一轮检测完成两个节点后中断
+	proxyutils.ResetRenameCounter()
+	pc := NewProxyChecker(4)
+	pc.ctx = context.Background()
+	for i := range 2 {
+		res := node(i)
+		pc.updateProxyName(res, 0)
+		pc.results = append(pc.results, *res)
+		pc.markTested(res.Proxy)
+		pc.recordSubTested(res.Proxy)
+	}
+	pc.saveCheckpoint()
+
+	// 重启后恢复进度，再检测两个新节点
+	proxyutils.ResetRenameCounter()
+	cp := loadCheckpoint()
+	if cp == nil {
+		t.Fatal("loadCheckpoint() = nil")
+	}
+	resumed := NewProxyChecker(4)
+	resumed.ctx = context.Background()
+	resumed.resume(cp)
+	if len(resumed.results) != 2 || len(resumed.tested) != 2 || resumed.subTested["sub"] != 2 {
+		t.Fatalf("resume() results = %d, tested = %d, subTested = %d, want 2, 2, 2",
+			len(resumed.results), len(resumed.tested), resumed.subTested["sub"])
+	}
+	for _, res := range resumed.results {
+		if !resumed.tested[nodeKey(res.Proxy)] {
+			t.Errorf("resumed node %v not marked tested", res.Proxy["name"])
+		}
+	}
+	for i := 2; i < 4; i++ {
+		res := node(i)
+		resumed.updateProxyName(res, 0)
+		resumed.results = append(resumed.results, *res)
+	}
+
+	names := make(map[string]bool)
+	for _, res := range resumed.results {
+		name := res.Proxy["name"].(string)
+		if names[name] {
+			t.Errorf("duplicate node name after resume: %s", name)
+		}
+		names[name] = true
+	}
+}
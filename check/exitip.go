@@ -0,0 +1,170 @@
+package check
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// ExitGroup 共用同一出口IP的节点分组
+type ExitGroup struct {
+	IP      string   `json:"ip"`
+	Country string   `json:"country"`
+	Kept    []string `json:"kept"`
+	Dropped []string `json:"dropped"`
+}
+
+// exitIPDedup 按出口IP限制可用节点数量，每个出口只保留最好的 keep 个节点
+// 开启测速时按速度从高到低，否则按延迟从低到高；出口已满时更好的节点替换最差的已保留节点
+// 在配额和重命名之前判断，被去重的节点不占用配额和编号；nil 表示不去重
+type exitIPDedup struct {
+	mu      sync.Mutex
+	keep    int
+	kept    map[string][]*Result // 出口IP -> 保留的节点，重命名后在报告中显示新名称
+	dropped map[string][]string  // 出口IP -> 被去重节点的名称
+	evicted map[string]bool      // 已保留后又被替换掉的节点，收集结果时排除
+	country map[string]string
+	order   []string
+	results []Result // 被去重的节点，只用于记录节点历史
+}
+
+func newExitIPDedup(keep int) *exitIPDedup {
+	if keep <= 0 {
+		return nil
+	}
+	return &exitIPDedup{
+		keep:    keep,
+		kept:    make(map[string][]*Result),
+		dropped: make(map[string][]string),
+		evicted: make(map[string]bool),
+		country: make(map[string]string),
+	}
+}
+
+// add 记录一个保留的节点，调用方需持有锁
+func (d *exitIPDedup) add(res *Result) {
+	if _, ok := d.kept[res.IP]; !ok {
+		d.order = append(d.order, res.IP)
+		d.country[res.IP] = res.Country
+	}
+	d.kept[res.IP] = append(d.kept[res.IP], res)
+}
+
+// drop 记录一个被去重的节点，调用方需持有锁
+func (d *exitIPDedup) drop(res *Result) {
+	name, _ := res.Proxy["name"].(string)
+	d.dropped[res.IP] = append(d.dropped[res.IP], name)
+	d.results = append(d.results, *res)
+	slog.Debug(fmt.Sprintf("出口IP重复，丢弃: %v", name), "ip", res.IP)
+}
+
+// restore 计入上次中断前已保留的节点
+func (d *exitIPDedup) restore(res *Result) {
+	if d == nil || res.IP == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.add(res)
+}
+
+// claim 为节点占用出口名额，没有出口IP的节点总是保留
+// 出口已满时，比最差的已保留节点更好则替换它并返回被替换的节点，否则返回 false
+// 被替换的节点在调用方确认后通过 evict 移除，确认前可以用 release 撤销
+func (d *exitIPDedup) claim(res *Result) (bool, *Result) {
+	if d == nil || res.IP == "" {
+		return true, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	members := d.kept[res.IP]
+	if len(members) < d.keep {
+		d.add(res)
+		return true, nil
+	}
+
+	worst := 0
+	for i, kept := range members {
+		if betterResult(*members[worst], *kept) {
+			worst = i
+		}
+	}
+	if !betterResult(*res, *members[worst]) {
+		d.drop(res)
+		return false, nil
+	}
+	victim := members[worst]
+	members[worst] = res
+	return true, victim
+}
+
+// release 撤销 claim，用于节点随后因配额被丢弃的情况，被替换的节点恢复保留
+func (d *exitIPDedup) release(res, victim *Result) {
+	if d == nil || res.IP == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	members := d.kept[res.IP]
+	i := slices.Index(members, res)
+	if i < 0 {
+		return
+	}
+	if victim != nil {
+		members[i] = victim
+		return
+	}
+	d.kept[res.IP] = slices.Delete(members, i, i+1)
+}
+
+// evict 确认移除被替换的节点
+func (d *exitIPDedup) evict(victim *Result) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.evicted[nodeKey(victim.Proxy)] = true
+	d.drop(victim)
+}
+
+// isEvicted 节点是否已被替换掉
+func (d *exitIPDedup) isEvicted(proxy map[string]any) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.evicted[nodeKey(proxy)]
+}
+
+// groups 返回有节点被去重的出口分组，需在检测结束后调用
+func (d *exitIPDedup) groups() []ExitGroup {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var groups []ExitGroup
+	for _, ip := range d.order {
+		if len(d.dropped[ip]) == 0 {
+			continue
+		}
+		group := ExitGroup{IP: ip, Country: d.country[ip], Dropped: d.dropped[ip]}
+		for _, kept := range d.kept[ip] {
+			name, _ := kept.Proxy["name"].(string)
+			group.Kept = append(group.Kept, name)
+		}
+		groups = append(groups, group)
+	}
+	if len(groups) > 0 {
+		slog.Info(fmt.Sprintf("出口IP去重移除节点数量: %d", len(d.results)), "重复出口数", len(groups))
+	}
+	return groups
+}
+
+// betterResult 判断 a 是否优于 b
+func betterResult(a, b Result) bool {
+	if speedTestEnabled() && a.Speed != b.Speed {
+		return a.Speed > b.Speed
+	}
+	return a.Latency < b.Latency
+}
@@ -0,0 +1,161 @@
+package check
+
+import (
+	"context"
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+func exitNode(name, ip string, latency, speed int) *Result {
+	return &Result{Proxy: map[string]any{"name": name, "type": "ss", "server": name + ".com", "port": 443}, IP: ip, Country: "HK", Latency: latency, Speed: speed}
+}
+
+func TestExitIPDedup(t *testing.T) {
+	tests := []struct {
+		name     string
+		speed    bool
+		keep     int
+		results  []*Result
+		wantKept []string
+		wantDrop []string
+	}{
+		{
+			name:     "keep best by latency",
+			keep:     1,
+			results:  []*Result{exitNode("a", "1.1.1.1", 100, 0), exitNode("b", "1.1.1.1", 200, 0), exitNode("c", "2.2.2.2", 300, 0)},
+			wantKept: []string{"a", "c"},
+			wantDrop: []string{"b"},
+		},
+		{
+			name:     "slower node claiming first is displaced",
+			keep:     1,
+			results:  []*Result{exitNode("slow", "1.1.1.1", 300, 0), exitNode("fast", "1.1.1.1", 100, 0)},
+			wantKept: []string{"fast"},
+			wantDrop: []string{"slow"},
+		},
+		{
+			name:     "worst of two replaced",
+			keep:     2,
+			results:  []*Result{exitNode("a", "1.1.1.1", 200, 0), exitNode("b", "1.1.1.1", 300, 0), exitNode("c", "1.1.1.1", 100, 0), exitNode("d", "1.1.1.1", 400, 0)},
+			wantKept: []string{"a", "c"},
+			wantDrop: []string{"b", "d"},
+		},
+		{
+			name:     "by speed when speed test enabled",
+			speed:    true,
+			keep:     1,
+			results:  []*Result{exitNode("slow", "1.1.1.1", 100, 1000), exitNode("fast", "1.1.1.1", 300, 5000)},
+			wantKept: []string{"fast"},
+			wantDrop: []string{"slow"},
+		},
+		{
+			name:     "no exit ip",
+			keep:     1,
+			results:  []*Result{exitNode("a", "", 100, 0), exitNode("b", "", 200, 0)},
+			wantKept: nil,
+			wantDrop: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.speed {
+				url := config.GlobalConfig.SpeedTestUrl
+				config.GlobalConfig.SpeedTestUrl = "http://speed.example.com"
+				defer func() { config.GlobalConfig.SpeedTestUrl = url }()
+			}
+			d := newExitIPDedup(tt.keep)
+			for _, res := range tt.results {
+				if ok, victim := d.claim(res); ok && victim != nil {
+					d.evict(victim)
+				}
+			}
+
+			var kept, dropped []string
+			for _, ip := range d.order {
+				for _, res := range d.kept[ip] {
+					kept = append(kept, res.Proxy["name"].(string))
+				}
+				dropped = append(dropped, d.dropped[ip]...)
+			}
+			if !sameNames(kept, tt.wantKept) {
+				t.Errorf("kept = %v, want %v", kept, tt.wantKept)
+			}
+			if !sameNames(dropped, tt.wantDrop) {
+				t.Errorf("dropped = %v, want %v", dropped, tt.wantDrop)
+			}
+		})
+	}
+}
+
+func sameNames(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := make(map[string]int)
+	for _, name := range got {
+		seen[name]++
+	}
+	for _, name := range want {
+		if seen[name] == 0 {
+			return false
+		}
+		seen[name]--
+	}
+	return true
+}
+
+func TestExitIPDedupRelease(t *testing.T) {
+	d := newExitIPDedup(1)
+	slow := exitNode("slow", "1.1.1.1", 300, 0)
+	fast := exitNode("fast", "1.1.1.1", 100, 0)
+
+	d.claim(slow)
+	ok, victim := d.claim(fast)
+	if !ok || victim != slow {
+		t.Fatalf("claim(fast) = %v, %v, want true, slow", ok, victim)
+	}
+	// fast 随后因配额被丢弃，slow 恢复保留
+	d.release(fast, victim)
+	if kept := d.kept["1.1.1.1"]; len(kept) != 1 || kept[0] != slow {
+		t.Errorf("kept after release = %v, want [slow]", kept)
+	}
+	if d.isEvicted(slow.Proxy) {
+		t.Error("slow evicted after release")
+	}
+}
+
+func TestAdmitEvictsWorseNode(t *testing.T) {
+	cfg := *config.GlobalConfig
+	t.Cleanup(func() { *config.GlobalConfig = cfg })
+	config.GlobalConfig.ExitIPDedup = 1
+	config.GlobalConfig.SuccessLimit = 0
+
+	pc := NewProxyChecker(2)
+	pc.ctx = context.Background()
+	slow := exitNode("slow", "1.1.1.1", 300, 0)
+	fast := exitNode("fast", "1.1.1.1", 100, 0)
+
+	if !pc.admit(slow) {
+		t.Fatal("admit(slow) = false")
+	}
+	// slow 已经被收集
+	pc.results = append(pc.results, *slow)
+	if !pc.admit(fast) {
+		t.Fatal("admit(fast) = false")
+	}
+
+	if len(pc.results) != 0 {
+		t.Errorf("results = %d, want slow removed", len(pc.results))
+	}
+	if !pc.exitIPs.isEvicted(slow.Proxy) {
+		t.Error("slow not marked evicted")
+	}
+	if pc.quota.admitted != 1 || pc.available != 1 {
+		t.Errorf("admitted = %d, available = %d, want 1, 1", pc.quota.admitted, pc.available)
+	}
+	groups := pc.exitIPs.groups()
+	if len(groups) != 1 || len(groups[0].Kept) != 1 || groups[0].Kept[0] != "fast" {
+		t.Errorf("groups() = %+v, want fast kept", groups)
+	}
+}
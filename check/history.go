@@ -0,0 +1,148 @@
+package check
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/beck-8/subs-check/utils"
+)
+
+const (
+	nodeHistoryFile = "node-history.json"
+	// 超过这个时间没有出现的节点从历史中清除
+	nodeHistoryKeep = 30 * 24 * time.Hour
+	// 测速结果按这个速度(KB/s)归一化，超过的都算满分
+	historySpeedFull = 10 * 1024
+)
+
+// nodeHistory 单个节点的历史检测记录，按节点指纹保存
+type nodeHistory struct {
+	Tested   int       `json:"tested"`
+	Passed   int       `json:"passed"`
+	Speed    int       `json:"speed,omitempty"` // 最近一次通过时的速度(KB/s)
+	LastSeen time.Time `json:"last_seen"`
+	LastPass time.Time `json:"last_pass,omitempty"`
+}
+
+// score 综合通过率、速度和最近一次通过的时间，范围 0-1
+func (h *nodeHistory) score() float64 {
+	// 平滑处理，测试次数少的节点不会因为一两次结果走到极端
+	passRate := float64(h.Passed+1) / float64(h.Tested+2)
+	speed := math.Min(float64(h.Speed)/historySpeedFull, 1)
+	var recency float64
+	if !h.LastPass.IsZero() {
+		recency = math.Exp(-time.Since(h.LastPass).Hours() / 72)
+	}
+	return passRate*0.6 + speed*0.2 + recency*0.2
+}
+
+// failing 多次测试从未通过的节点
+func (h *nodeHistory) failing() bool {
+	return h.Tested >= 2 && h.Passed == 0
+}
+
+func loadNodeHistory() map[string]*nodeHistory {
+	history := make(map[string]*nodeHistory)
+	if err := utils.LoadState(nodeHistoryFile, &history); err != nil {
+		slog.Warn(fmt.Sprintf("加载节点历史失败: %v", err))
+	}
+	return history
+}
+
+// prioritize 按历史表现排序待测节点
+// 有历史的节点按得分从高到低，新节点按比例均匀插入其中，多次测试从未通过的节点放在最后
+func prioritize(proxies []map[string]any, history map[string]*nodeHistory) []map[string]any {
+	type scored struct {
+		proxy map[string]any
+		score float64
+	}
+	var known []scored
+	var unseen, failing []map[string]any
+	for _, proxy := range proxies {
+		h, ok := history[nodeKey(proxy)]
+		switch {
+		case !ok:
+			unseen = append(unseen, proxy)
+		case h.failing():
+			failing = append(failing, proxy)
+		default:
+			known = append(known, scored{proxy, h.score()})
+		}
+	}
+	sort.SliceStable(known, func(i, j int) bool {
+		return known[i].score > known[j].score
+	})
+
+	ordered := make([]map[string]any, 0, len(proxies))
+	total := len(known) + len(unseen)
+	for i, k, u := 0, 0, 0; i < total; i++ {
+		// 按两组节点数量的比例交替取，保证新节点分散在整个队列里
+		if u >= len(unseen) || (k < len(known) && k*len(unseen) <= u*len(known)) {
+			ordered = append(ordered, known[k].proxy)
+			k++
+		} else {
+			ordered = append(ordered, unseen[u])
+			u++
+		}
+	}
+	ordered = append(ordered, failing...)
+
+	slog.Info("按历史表现排序节点", "有记录", len(known), "新节点", len(unseen), "多次失败", len(failing))
+	return ordered
+}
+
+// updateNodeHistory 记录本轮的检测结果并保存，未派发的节点只更新出现时间
+// 从中断处恢复的节点在被强制关闭的那一轮已经记录，不重复统计
+func (pc *ProxyChecker) updateNodeHistory(history map[string]*nodeHistory, proxies []map[string]any) {
+	now := time.Now()
+	for _, proxy := range proxies {
+		if h, ok := history[nodeKey(proxy)]; ok {
+			h.LastSeen = now
+		}
+	}
+
+	pc.subLock.Lock()
+	for key := range pc.tested {
+		if pc.resumed[key] {
+			continue
+		}
+		h, ok := history[key]
+		if !ok {
+			h = &nodeHistory{LastSeen: now}
+			history[key] = h
+		}
+		h.Tested++
+	}
+	pc.subLock.Unlock()
+
+	// 超出配额和出口IP重复的节点也是检测通过的
+	passed := append(pc.results[:len(pc.results):len(pc.results)], pc.quota.overQuota...)
+	if pc.exitIPs != nil {
+		passed = append(passed, pc.exitIPs.results...)
+	}
+	for _, result := range passed {
+		key := nodeKey(result.Proxy)
+		if pc.resumed[key] {
+			continue
+		}
+		if h, ok := history[key]; ok {
+			h.Passed++
+			h.LastPass = now
+			if result.Speed > 0 {
+				h.Speed = result.Speed
+			}
+		}
+	}
+
+	for key, h := range history {
+		if now.Sub(h.LastSeen) > nodeHistoryKeep {
+			delete(history, key)
+		}
+	}
+	if err := utils.SaveState(nodeHistoryFile, history); err != nil {
+		slog.Warn(fmt.Sprintf("保存节点历史失败: %v", err))
+	}
+}
@@ -0,0 +1,88 @@
+package check
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+func historyNode(name string) map[string]any {
+	return map[string]any{"name": name, "type": "ss", "server": name + ".com", "port": 443, "cipher": "aes-128-gcm", "password": "p"}
+}
+
+func TestPrioritize(t *testing.T) {
+	now := time.Now()
+	good, bad, failing := historyNode("good"), historyNode("bad"), historyNode("failing")
+	history := map[string]*nodeHistory{
+		nodeKey(good):    {Tested: 5, Passed: 5, Speed: 10240, LastPass: now},
+		nodeKey(bad):     {Tested: 5, Passed: 1, LastPass: now.Add(-240 * time.Hour)},
+		nodeKey(failing): {Tested: 3},
+	}
+
+	tests := []struct {
+		name    string
+		proxies []map[string]any
+		want    []string
+	}{
+		{
+			name:    "by score, failing last",
+			proxies: []map[string]any{failing, bad, good},
+			want:    []string{"good", "bad", "failing"},
+		},
+		{
+			name:    "unseen interleaved",
+			proxies: []map[string]any{historyNode("new1"), bad, historyNode("new2"), good},
+			want:    []string{"good", "new1", "bad", "new2"},
+		},
+		{
+			name:    "only unseen keeps order",
+			proxies: []map[string]any{historyNode("a"), historyNode("b"), historyNode("c")},
+			want:    []string{"a", "b", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := prioritize(tt.proxies, history)
+			if len(got) != len(tt.want) {
+				t.Fatalf("prioritize() returned %d nodes, want %d", len(got), len(tt.want))
+			}
+			for i, proxy := range got {
+				if proxy["name"] != tt.want[i] {
+					t.Errorf("prioritize()[%d] = %v, want %s", i, proxy["name"], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateNodeHistoryResumed(t *testing.T) {
+	cfg := *config.GlobalConfig
+	t.Cleanup(func() { *config.GlobalConfig = cfg })
+	config.GlobalConfig.StateDir = t.TempDir()
+
+	old, fresh := historyNode("old"), historyNode("fresh")
+	history := map[string]*nodeHistory{
+		nodeKey(old): {Tested: 1, Passed: 1, LastSeen: time.Now()},
+	}
+
+	// old 在上一轮中断前已通过检测，恢复后不再计数
+	pc := NewProxyChecker(2)
+	pc.ctx = context.Background()
+	pc.resume(&checkpoint{
+		StartTime: time.Now(),
+		Tested:    []string{nodeKey(old)},
+		Results:   []Result{{Proxy: old}},
+	})
+	pc.markTested(fresh)
+	pc.results = append(pc.results, Result{Proxy: fresh})
+
+	pc.updateNodeHistory(history, []map[string]any{old, fresh})
+	if h := history[nodeKey(old)]; h.Tested != 1 || h.Passed != 1 {
+		t.Errorf("resumed node history = %d/%d, want 1/1", h.Passed, h.Tested)
+	}
+	if h := history[nodeKey(fresh)]; h == nil || h.Tested != 1 || h.Passed != 1 {
+		t.Errorf("new node history = %+v, want 1/1", h)
+	}
+}
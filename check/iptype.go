@@ -0,0 +1,126 @@
+package check
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/beck-8/subs-check/config"
+	proxyutils "github.com/beck-8/subs-check/proxy"
+)
+
+// 出口IP类型
+const (
+	IPTypeResidential = "residential"
+	IPTypeMobile      = "mobile"
+	IPTypeHosting     = "hosting"
+)
+
+// ipTypeTags 出口类型在节点名中的标记
+var ipTypeTags = map[string]string{
+	IPTypeResidential: "Res",
+	IPTypeMobile:      "Mob",
+	IPTypeHosting:     "DC",
+}
+
+// hostingASNs 常见云厂商/IDC 的 ASN
+var hostingASNs = map[string]bool{
+	"AS16509":  true, // Amazon
+	"AS14618":  true, // Amazon
+	"AS15169":  true, // Google
+	"AS396982": true, // Google Cloud
+	"AS8075":   true, // Microsoft
+	"AS13335":  true, // Cloudflare
+	"AS14061":  true, // DigitalOcean
+	"AS63949":  true, // Linode
+	"AS20940":  true, // Akamai
+	"AS20473":  true, // Vultr
+	"AS16276":  true, // OVH
+	"AS24940":  true, // Hetzner
+	"AS51167":  true, // Contabo
+	"AS31898":  true, // Oracle
+	"AS45102":  true, // Alibaba
+	"AS37963":  true, // Alibaba
+	"AS132203": true, // Tencent
+	"AS45090":  true, // Tencent
+	"AS9009":   true, // M247
+	"AS60781":  true, // Leaseweb
+	"AS906":    true, // DMIT
+	"AS25820":  true, // IT7
+	"AS21859":  true, // Zenlayer
+	"AS199524": true, // G-Core
+	"AS54113":  true, // Fastly
+	"AS62240":  true, // Clouvider
+	"AS212238": true, // Datacamp
+}
+
+var (
+	hostingKeywords     = []string{"hosting", "cloud", "data center", "datacenter", "server", "vps", "colo", "idc"}
+	mobileKeywords      = []string{"mobile", "wireless", "cellular"}
+	residentialKeywords = []string{"broadband", "cable", "dsl", "fiber", "fibre", "ftth", "residential", "home"}
+)
+
+// classifyIP 判断出口IP类型，优先级：自定义CIDR > 自定义ASN > IP风险接口分类 > 内置ASN > 运营商名称关键字
+// 只有明确的依据才判断为家宽，中转、IDC 和未知运营商返回空
+func classifyIP(res *Result) string {
+	if res.IP == "" {
+		return ""
+	}
+
+	if addr, err := netip.ParseAddr(res.IP); err == nil {
+		addr = addr.Unmap()
+		for typ, cidrs := range config.GlobalConfig.IPTypeCIDR {
+			for _, cidr := range cidrs {
+				if prefix, err := netip.ParsePrefix(cidr); err == nil && prefix.Contains(addr) {
+					return typ
+				}
+			}
+		}
+	}
+
+	asn := strings.ToUpper(res.ASN)
+	if asn != "" {
+		for typ, asns := range config.GlobalConfig.IPTypeASN {
+			for _, a := range asns {
+				a = strings.ToUpper(a)
+				if !strings.HasPrefix(a, "AS") {
+					a = "AS" + a
+				}
+				if a == asn {
+					return typ
+				}
+			}
+		}
+	}
+
+	switch res.RiskType {
+	case proxyutils.RiskDatacenter:
+		return IPTypeHosting
+	case proxyutils.RiskResidential:
+		return IPTypeResidential
+	}
+
+	if hostingASNs[asn] {
+		return IPTypeHosting
+	}
+
+	isp := strings.ToLower(res.ISP)
+	if isp == "" {
+		return ""
+	}
+	for _, k := range hostingKeywords {
+		if strings.Contains(isp, k) {
+			return IPTypeHosting
+		}
+	}
+	for _, k := range mobileKeywords {
+		if strings.Contains(isp, k) {
+			return IPTypeMobile
+		}
+	}
+	for _, k := range residentialKeywords {
+		if strings.Contains(isp, k) {
+			return IPTypeResidential
+		}
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package check
+
+import (
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+	proxyutils "github.com/beck-8/subs-check/proxy"
+)
+
+func TestClassifyIP(t *testing.T) {
+	config.GlobalConfig.IPTypeASN = map[string][]string{"residential": {"4837"}}
+	config.GlobalConfig.IPTypeCIDR = map[string][]string{"mobile": {"223.104.0.0/14"}}
+	defer func() {
+		config.GlobalConfig.IPTypeASN = nil
+		config.GlobalConfig.IPTypeCIDR = nil
+	}()
+
+	tests := []struct {
+		name string
+		res  Result
+		want string
+	}{
+		{"no ip", Result{ISP: "Comcast Cable"}, ""},
+		{"custom cidr", Result{IP: "223.104.1.1", ASN: "AS16509"}, IPTypeMobile},
+		{"custom asn", Result{IP: "1.1.1.1", ASN: "as4837"}, IPTypeResidential},
+		{"risk residential", Result{IP: "1.1.1.1", RiskType: proxyutils.RiskResidential}, IPTypeResidential},
+		{"risk datacenter", Result{IP: "1.1.1.1", RiskType: proxyutils.RiskDatacenter, ISP: "Comcast Cable"}, IPTypeHosting},
+		{"builtin asn", Result{IP: "1.1.1.1", ASN: "AS13335"}, IPTypeHosting},
+		{"hosting keyword", Result{IP: "1.1.1.1", ISP: "Example Cloud Ltd"}, IPTypeHosting},
+		{"mobile keyword", Result{IP: "1.1.1.1", ISP: "T-Mobile USA"}, IPTypeMobile},
+		{"residential keyword", Result{IP: "1.1.1.1", ISP: "Comcast Cable Communications"}, IPTypeResidential},
+		{"transit", Result{IP: "1.1.1.1", ISP: "Cogent Communications"}, ""},
+		{"unknown isp", Result{IP: "1.1.1.1", ISP: "Example Networks"}, ""},
+		{"no isp", Result{IP: "1.1.1.1"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyIP(&tt.res); got != tt.want {
+				t.Errorf("classifyIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
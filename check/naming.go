@@ -0,0 +1,119 @@
+package check
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+
+	"github.com/beck-8/subs-check/config"
+	proxyutils "github.com/beck-8/subs-check/proxy"
+)
+
+// NameData 命名模板中可用的字段
+type NameData struct {
+	Prefix    string            // node-prefix
+	Name      string            // 原始节点名
+	Code      string            // 国家代码，如 HK
+	Country   string            // 国家名称
+	Flag      string            // 国旗 emoji
+	Region    string            // 省/州
+	City      string            // 城市
+	Lat       float64           // 纬度
+	Lon       float64           // 经度
+	ASN       string            // 形如 AS13335
+	ISP       string            // 运营商/组织
+	IP        string            // 出口IP
+	Latency   int               // 延迟(ms)
+	UDP       bool              // 是否通过UDP检测，需开启 udp-check
+	UDPRtt    int               // UDP 往返耗时(ms)
+	Speed     int               // 下载速度(KB/s)
+	SpeedText string            // 格式化后的速度，如 1.2MB/s
+	Peak      int               // 下载峰值速度(KB/s)
+	Upload    int               // 上传速度(KB/s)
+	UpText    string            // 格式化后的上传速度
+	RiskScore int               // IP风险分数 0-100，未检测为 -1
+	RiskType  string            // IP风险分类，如 residential
+	IPType    string            // 出口类型 residential/mobile/hosting
+	EntryIP   string            // 入口IP，需开启 entry-geo
+	EntryCode string            // 入口国家代码，需开启 entry-geo
+	ClaimCode string            // 原名中声称的国家代码
+	Platforms map[string]string // 平台 -> 解锁标记，如 netflix -> NF，未解锁的不存在
+	Tags      []string          // 按 platforms 顺序排列的解锁标记
+	Protocol  string            // 节点协议，如 vmess
+	SubTag    string            // 订阅备注
+	Index     int               // 同一国家内的序号
+}
+
+var nameTemplateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// parseNameTemplate 解析 name-template，未配置或解析失败时返回 nil 使用默认命名
+func parseNameTemplate() *template.Template {
+	if config.GlobalConfig.NameTemplate == "" {
+		return nil
+	}
+	tmpl, err := template.New("name").Funcs(nameTemplateFuncs).Option("missingkey=zero").Parse(config.GlobalConfig.NameTemplate)
+	if err != nil {
+		slog.Error(fmt.Sprintf("解析命名模板失败，使用默认命名: %v", err))
+		return nil
+	}
+	return tmpl
+}
+
+// renderName 使用模板生成节点名称，index 为节点在国家内的序号
+func renderName(tmpl *template.Template, res *Result, index int) (string, error) {
+	code := strings.ToUpper(res.Country)
+	data := NameData{
+		Prefix:    config.GlobalConfig.NodePrefix,
+		Code:      code,
+		Country:   proxyutils.CountryName(code),
+		Flag:      proxyutils.CountryCodeToFlag(code),
+		Region:    res.Region,
+		City:      res.City,
+		Lat:       res.Lat,
+		Lon:       res.Lon,
+		ASN:       res.ASN,
+		ISP:       res.ISP,
+		IP:        res.IP,
+		Latency:   res.Latency,
+		UDP:       res.UDP,
+		UDPRtt:    res.UDPLatency,
+		Speed:     res.Speed,
+		SpeedText: formatSpeed(res.Speed),
+		Peak:      res.PeakSpeed,
+		Upload:    res.Upload,
+		UpText:    formatSpeed(res.Upload),
+		RiskScore: res.RiskScore,
+		RiskType:  res.RiskType,
+		IPType:    res.IPType,
+		EntryIP:   res.EntryIP,
+		EntryCode: res.EntryCode,
+		ClaimCode: res.ClaimCode,
+		Platforms: make(map[string]string),
+		Tags:      platformTags(res),
+		Index:     index,
+	}
+	data.Name, _ = res.Proxy["name"].(string)
+	data.Protocol, _ = res.Proxy["type"].(string)
+	data.SubTag, _ = res.Proxy["sub_tag"].(string)
+	for _, plat := range config.GlobalConfig.Platforms {
+		if tag := platformTag(res, plat); tag != "" {
+			data.Platforms[plat] = tag
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(buf.String())
+	if name == "" {
+		return "", fmt.Errorf("模板生成的名称为空")
+	}
+	return name, nil
+}
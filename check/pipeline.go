@@ -0,0 +1,337 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/beck-8/subs-check/check/platform"
+	"github.com/beck-8/subs-check/config"
+	proxyutils "github.com/beck-8/subs-check/proxy"
+)
+
+// 检测分为三个阶段：
+//   存活检测：请求量小，高并发，尽快淘汰失效节点
+//   测速：占用带宽，并发单独限制
+//   流媒体：解锁、IP风险、位置查询和重命名
+// 每个阶段重新创建代理Client，超时时间也按阶段单独计算
+
+// runStage 启动 n 个线程执行 worker 并等待全部退出
+func runStage(n int, worker func()) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker()
+		}()
+	}
+	wg.Wait()
+}
+
+// aliveWorker 存活检测线程
+func (pc *ProxyChecker) aliveWorker() {
+	g := pc.adaptive.gate(stageAlive)
+	for proxy := range pc.tasks {
+		g.acquire()
+		ctx, cancel := context.WithTimeout(pc.ctx, proxyTimeout())
+		res, err := pc.checkAlive(ctx, proxy)
+		pc.adaptive.observe(stageAlive, res != nil, isTimeout(err) || ctx.Err() != nil)
+		cancel()
+		g.release()
+		pc.recordSubTested(proxy)
+
+		switch {
+		case res == nil:
+			pc.dropNode(proxy)
+		case os.Getenv("SUB_CHECK_SKIP") != "":
+			pc.resultChan <- *res
+			pc.incrementProgress()
+		case needSpeedStage():
+			pc.speedTasks <- res
+		default:
+			pc.mediaTasks <- res
+		}
+	}
+}
+
+// speedWorker 测速线程
+func (pc *ProxyChecker) speedWorker() {
+	g := pc.adaptive.gate(stageSpeed)
+	for res := range pc.speedTasks {
+		if pc.skipQueued(res) {
+			pc.incrementProgress()
+			continue
+		}
+		g.acquire()
+		ctx, cancel := context.WithTimeout(pc.ctx, proxyTimeout())
+		ok := pc.checkSpeed(ctx, res)
+		pc.adaptive.observe(stageSpeed, ok, ctx.Err() != nil)
+		cancel()
+		g.release()
+		if !ok {
+			pc.dropNode(res.Proxy)
+			continue
+		}
+		pc.mediaTasks <- res
+	}
+}
+
+// mediaWorker 流媒体等检测线程，也是最后一个阶段
+func (pc *ProxyChecker) mediaWorker() {
+	g := pc.adaptive.gate(stageMedia)
+	for res := range pc.mediaTasks {
+		if pc.skipQueued(res) {
+			pc.incrementProgress()
+			continue
+		}
+		g.acquire()
+		ctx, cancel := context.WithTimeout(pc.ctx, proxyTimeout())
+		ok := pc.checkMedia(ctx, res)
+		pc.adaptive.observe(stageMedia, ok, ctx.Err() != nil)
+		cancel()
+		g.release()
+		if !ok {
+			pc.dropNode(res.Proxy)
+			continue
+		}
+		// 可用节点在收集结果时记录，保证进度里的节点和结果一致
+		pc.resultChan <- *res
+		pc.incrementProgress()
+	}
+}
+
+// dropNode 节点未通过检测，因整轮检测被取消而失败的不记录，恢复时会重新检测
+func (pc *ProxyChecker) dropNode(proxy map[string]any) {
+	if pc.ctx.Err() == nil {
+		pc.markTested(proxy)
+	}
+	pc.incrementProgress()
+}
+
+// skipQueued 已达到数量限制或收到强制关闭信号时，丢弃还在队列中的节点
+func (pc *ProxyChecker) skipQueued(res *Result) bool {
+	if pc.ctx.Err() != nil {
+		return true
+	}
+	if pc.limitReached() {
+		slog.Debug(fmt.Sprintf("达到节点数量限制，跳过: %v", res.Proxy["name"]))
+		return true
+	}
+	return false
+}
+
+// limitReached 是否达到 success-limit，配置了 min-per-country 时还要求各国家都已满足
+func (pc *ProxyChecker) limitReached() bool {
+	return config.GlobalConfig.SuccessLimit > 0 && pc.quota.full()
+}
+
+// needSpeedStage 是否需要经过测速阶段
+func needSpeedStage() bool {
+	return speedTestEnabled() || config.GlobalConfig.UploadTestUrl != ""
+}
+
+// checkAlive 存活检测，通过时返回记录了延迟的结果
+func (pc *ProxyChecker) checkAlive(ctx context.Context, proxy map[string]any) (*Result, error) {
+	select {
+	case <-ctx.Done():
+		slog.Warn("节点检测超时/取消，跳过", "name", proxy["name"])
+		return nil, ctx.Err()
+	default:
+	}
+
+	res := &Result{
+		Proxy:     proxy,
+		RiskScore: -1,
+	}
+
+	if os.Getenv("SUB_CHECK_SKIP") != "" {
+		// slog.Debug(fmt.Sprintf("跳过检测代理: %v", proxy["name"]))
+		return res, nil
+	}
+
+	httpClient := CreateClient(ctx, proxy)
+	if httpClient == nil {
+		slog.Debug(fmt.Sprintf("创建代理Client失败: %v", proxy["name"]))
+		return nil, nil
+	}
+	defer httpClient.Close()
+
+	aliveStart := time.Now()
+	google, err := platform.CheckAlive(ctx, httpClient.Client)
+	if err != nil || !google {
+		return nil, err
+	}
+	res.Latency = int(time.Since(aliveStart).Milliseconds())
+	return res, nil
+}
+
+// checkSpeed 下载和上传测速，低于最低速度时返回 false
+func (pc *ProxyChecker) checkSpeed(ctx context.Context, res *Result) bool {
+	httpClient := CreateClient(ctx, res.Proxy)
+	if httpClient == nil {
+		return false
+	}
+	defer httpClient.Close()
+
+	if speedTestEnabled() {
+		sr, err := platform.CheckSpeed(ctx, httpClient.Client, Bucket, httpClient.BytesRead)
+		if err != nil || sr.Speed < config.GlobalConfig.MinSpeed {
+			return false
+		}
+		res.Speed = sr.Speed
+		res.PeakSpeed = sr.Peak
+	}
+
+	if config.GlobalConfig.UploadTestUrl != "" {
+		select {
+		case <-ctx.Done():
+			return false
+		default:
+		}
+		upload, _, err := platform.CheckUpload(ctx, httpClient.Client, httpClient.BytesWritten)
+		if err != nil || upload < config.GlobalConfig.MinUploadSpeed {
+			return false
+		}
+		res.Upload = upload
+	}
+	return true
+}
+
+// checkMedia UDP、流媒体解锁、IP风险和位置查询，最后重命名节点
+func (pc *ProxyChecker) checkMedia(ctx context.Context, res *Result) bool {
+	proxy := res.Proxy
+	httpClient := CreateClient(ctx, proxy)
+	if httpClient == nil {
+		return false
+	}
+	defer httpClient.Close()
+
+	if config.GlobalConfig.UDPCheck {
+		if rtt, err := httpClient.CheckUDP(ctx); err == nil {
+			res.UDP = true
+			res.UDPLatency = rtt
+		} else {
+			slog.Debug(fmt.Sprintf("UDP检测失败: %v", proxy["name"]), "error", err)
+		}
+	}
+
+	if config.GlobalConfig.MediaCheck {
+		// 遍历需要检测的平台
+		for _, plat := range config.GlobalConfig.Platforms {
+			switch plat {
+			case "openai":
+				cookiesOK, clientOK := platform.CheckOpenAI(ctx, httpClient.Client)
+				if clientOK && cookiesOK {
+					res.Openai = true
+				} else if cookiesOK || clientOK {
+					res.OpenaiWeb = true
+				}
+			case "youtube":
+				if region, _ := platform.CheckYoutube(ctx, httpClient.Client); region != "" {
+					res.Youtube = region
+				}
+			case "netflix":
+				if ok, _ := platform.CheckNetflix(ctx, httpClient.Client); ok {
+					res.Netflix = true
+				}
+			case "disney":
+				if ok, _ := platform.CheckDisney(ctx, httpClient.Client); ok {
+					res.Disney = true
+				}
+			case "gemini":
+				if ok, _ := platform.CheckGemini(ctx, httpClient.Client); ok {
+					res.Gemini = true
+				}
+			case "iprisk":
+				pc.checkRisk(ctx, res, httpClient.Client)
+			case "tiktok":
+				if region, _ := platform.CheckTikTok(ctx, httpClient.Client); region != "" {
+					res.TikTok = region
+				}
+			}
+		}
+	}
+
+	// 配置了风险阈值时即使没开 iprisk 检测也要查询
+	if config.GlobalConfig.MaxIPRisk > 0 {
+		if res.RiskScore < 0 {
+			pc.checkRisk(ctx, res, httpClient.Client)
+		}
+		if res.RiskScore > config.GlobalConfig.MaxIPRisk {
+			slog.Debug(fmt.Sprintf("IP风险过高，丢弃: %v", proxy["name"]), "ip", res.IP, "score", res.RiskScore, "type", res.RiskType)
+			return false
+		}
+	}
+
+	// 重命名前记录原名声称的国家
+	if name, ok := proxy["name"].(string); ok {
+		res.ClaimCode = proxyutils.ClaimedCountry(name)
+	}
+	if config.GlobalConfig.EntryGeo {
+		entry := proxyutils.GetEntryGeo(proxy)
+		res.EntryIP, res.EntryCode = entry.IP, entry.Country
+	}
+
+	// 重命名、出口IP去重和出口类型都依赖出口IP，没有查过时补查一次
+	if res.IP == "" && needGeo() {
+		res.setGeo(proxyutils.GetProxyGeo(httpClient.Client))
+	}
+	res.IPType = classifyIP(res)
+
+	// 检测被取消时平台检测的结果不完整，不作为可用节点
+	if pc.ctx.Err() != nil {
+		return false
+	}
+
+	return pc.admit(res)
+}
+
+// admit 出口IP去重、配额检查和重命名，通过时计入可用节点
+// 重命名会占用编号，所以在重命名前做去重和配额检查，被丢弃的节点不占用名额和编号；
+// 出口IP已满时替换掉的节点归还名额和编号，从结果中移除
+func (pc *ProxyChecker) admit(res *Result) bool {
+	pc.admitLock.Lock()
+	defer pc.admitLock.Unlock()
+
+	ok, victim := pc.exitIPs.claim(res)
+	if !ok {
+		return false
+	}
+	if !pc.quota.replace(res, victim) {
+		pc.exitIPs.release(res, victim)
+		return false
+	}
+	if victim != nil {
+		pc.evict(victim)
+	}
+
+	// 更新代理名称
+	pc.updateProxyName(res, res.Speed)
+	pc.incrementAvailable()
+	return true
+}
+
+// evict 移除被同出口更好的节点替换掉的节点
+func (pc *ProxyChecker) evict(victim *Result) {
+	pc.exitIPs.evict(victim)
+	if victim.Index > 0 {
+		proxyutils.ReleaseIndex(strings.ToUpper(victim.Country), victim.Proxy, victim.Index)
+	}
+	atomic.AddInt32(&pc.available, -1)
+	Available.Add(^uint32(0))
+
+	// 节点可能还没被收集，collectResults 会根据 isEvicted 跳过
+	key := nodeKey(victim.Proxy)
+	pc.resultLock.Lock()
+	pc.results = slices.DeleteFunc(pc.results, func(r Result) bool {
+		return nodeKey(r.Proxy) == key
+	})
+	pc.resultLock.Unlock()
+}
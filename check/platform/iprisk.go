@@ -1,62 +1,318 @@
 package platform
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/netip"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/beck-8/subs-check/config"
+	proxyutils "github.com/beck-8/subs-check/proxy"
 	"github.com/metacubex/mihomo/common/convert"
 )
 
-func CheckIPRisk(httpClient *http.Client, ip string) (string, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("https://scamalytics.com/ip/%s", ip), nil)
+// riskProvider 查询单个IP的风险数据
+type riskProvider func(ctx context.Context, httpClient *http.Client, ip string) (proxyutils.RiskInfo, error)
+
+var riskProviders = map[string]riskProvider{
+	"scamalytics":    checkScamalytics,
+	"ipqualityscore": checkIPQualityScore,
+	"proxycheck":     checkProxyCheck,
+	"abuseipdb":      checkAbuseIPDB,
+}
+
+// CheckIPRisk 按 ip-risk-providers 的顺序查询IP风险，返回第一个成功的结果
+func CheckIPRisk(ctx context.Context, httpClient *http.Client, ip string) (proxyutils.RiskInfo, error) {
+	names := config.GlobalConfig.IPRiskProviders
+	if len(names) == 0 {
+		names = []string{"scamalytics"}
+	}
+
+	var lastErr error
+	for _, name := range names {
+		provider, ok := riskProviders[strings.ToLower(name)]
+		if !ok {
+			lastErr = fmt.Errorf("未知的IP风险接口: %s", name)
+			continue
+		}
+		risk, err := provider(ctx, httpClient, ip)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", name, err)
+			continue
+		}
+		risk.Source = strings.ToLower(name)
+		return risk, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("没有可用的IP风险接口")
+	}
+	return proxyutils.RiskInfo{}, lastErr
+}
+
+func riskKey(name string) (string, error) {
+	key := config.GlobalConfig.IPRiskKeys[name]
+	if key == "" {
+		return "", fmt.Errorf("未配置 %s 的 api key", name)
+	}
+	return key, nil
+}
+
+func getRiskJSON(httpClient *http.Client, req *http.Request, v any) error {
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("返回状态码: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+var (
+	scamalyticsScore = regexp.MustCompile(`"score"\s*:\s*"?(\d+)"?`)
+	scamalyticsRisk  = regexp.MustCompile(`"risk"\s*:\s*"([^"]+)"`)
+)
+
+// checkScamalytics 从 scamalytics 页面内嵌的 json 中提取分数，无需 api key
+func checkScamalytics(ctx context.Context, httpClient *http.Client, ip string) (proxyutils.RiskInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://scamalytics.com/ip/%s", ip), nil)
+	if err != nil {
+		return proxyutils.RiskInfo{}, err
 	}
 	req.Header.Set("User-Agent", convert.RandUserAgent())
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return proxyutils.RiskInfo{}, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return proxyutils.RiskInfo{}, fmt.Errorf("返回状态码: %d", resp.StatusCode)
+	}
 
-	if resp.StatusCode == 200 {
-		// 读取响应内容
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", err
-		}
-		bodyStr := string(body)
-		apiIndex := strings.Index(bodyStr, "IP Fraud Risk API")
-		if apiIndex == -1 {
-			return "", fmt.Errorf("未找到IP Fraud Risk API")
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return proxyutils.RiskInfo{}, err
+	}
+	m := scamalyticsScore.FindSubmatch(body)
+	if m == nil {
+		return proxyutils.RiskInfo{}, fmt.Errorf("未找到风险分数")
+	}
+	score, _ := strconv.Atoi(string(m[1]))
+	risk := proxyutils.RiskInfo{Score: score}
+	if m := scamalyticsRisk.FindSubmatch(body); m != nil {
+		switch strings.ToLower(string(m[1])) {
+		case "high", "very high":
+			risk.Category = proxyutils.RiskAbuse
 		}
-		// 从 "IP Fraud Risk API" 后的内容开始
-		contentAfterAPI := bodyStr[apiIndex+len("IP Fraud Risk API"):]
-		// 按行分割
-		lines := strings.Split(contentAfterAPI, "\n")
+	}
+	return risk, nil
+}
+
+// checkIPQualityScore https://www.ipqualityscore.com/documentation/proxy-detection-api/overview
+func checkIPQualityScore(ctx context.Context, httpClient *http.Client, ip string) (proxyutils.RiskInfo, error) {
+	key, err := riskKey("ipqualityscore")
+	if err != nil {
+		return proxyutils.RiskInfo{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://ipqualityscore.com/api/json/ip/%s/%s", key, ip), nil)
+	if err != nil {
+		return proxyutils.RiskInfo{}, err
+	}
 
-		if len(lines) < 7 {
-			return "", fmt.Errorf("IP Fraud Risk API响应格式不正确")
+	var data struct {
+		Success        bool   `json:"success"`
+		Message        string `json:"message"`
+		FraudScore     int    `json:"fraud_score"`
+		Proxy          bool   `json:"proxy"`
+		VPN            bool   `json:"vpn"`
+		Tor            bool   `json:"tor"`
+		RecentAbuse    bool   `json:"recent_abuse"`
+		ConnectionType string `json:"connection_type"`
+	}
+	if err := getRiskJSON(httpClient, req, &data); err != nil {
+		return proxyutils.RiskInfo{}, err
+	}
+	if !data.Success {
+		return proxyutils.RiskInfo{}, fmt.Errorf("%s", data.Message)
+	}
+
+	risk := proxyutils.RiskInfo{Score: data.FraudScore}
+	switch {
+	case data.RecentAbuse:
+		risk.Category = proxyutils.RiskAbuse
+	case data.Proxy || data.VPN || data.Tor:
+		risk.Category = proxyutils.RiskProxy
+	case strings.EqualFold(data.ConnectionType, "Data Center"):
+		risk.Category = proxyutils.RiskDatacenter
+	case strings.EqualFold(data.ConnectionType, "Residential"), strings.EqualFold(data.ConnectionType, "Mobile"):
+		risk.Category = proxyutils.RiskResidential
+	}
+	return risk, nil
+}
+
+// checkProxyCheck https://proxycheck.io/api/ ，不配置 key 时使用免费额度
+func checkProxyCheck(ctx context.Context, httpClient *http.Client, ip string) (proxyutils.RiskInfo, error) {
+	url := fmt.Sprintf("https://proxycheck.io/v2/%s?vpn=1&risk=1", ip)
+	if key := config.GlobalConfig.IPRiskKeys["proxycheck"]; key != "" {
+		url += "&key=" + key
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return proxyutils.RiskInfo{}, err
+	}
+
+	var data map[string]json.RawMessage
+	if err := getRiskJSON(httpClient, req, &data); err != nil {
+		return proxyutils.RiskInfo{}, err
+	}
+	var status string
+	json.Unmarshal(data["status"], &status)
+	if status != "ok" && status != "warning" {
+		var message string
+		json.Unmarshal(data["message"], &message)
+		return proxyutils.RiskInfo{}, fmt.Errorf("状态 %s: %s", status, message)
+	}
+
+	var info struct {
+		Proxy string `json:"proxy"`
+		Type  string `json:"type"`
+		Risk  int    `json:"risk"`
+	}
+	if err := json.Unmarshal(data[ip], &info); err != nil {
+		return proxyutils.RiskInfo{}, fmt.Errorf("响应中没有该IP: %w", err)
+	}
+
+	risk := proxyutils.RiskInfo{Score: info.Risk}
+	switch {
+	case info.Risk >= 67:
+		risk.Category = proxyutils.RiskAbuse
+	case info.Proxy == "yes":
+		risk.Category = proxyutils.RiskProxy
+	case strings.EqualFold(info.Type, "Hosting"), strings.EqualFold(info.Type, "Business"):
+		risk.Category = proxyutils.RiskDatacenter
+	case strings.EqualFold(info.Type, "Residential"), strings.EqualFold(info.Type, "Wireless"):
+		risk.Category = proxyutils.RiskResidential
+	}
+	return risk, nil
+}
+
+// checkAbuseIPDB https://docs.abuseipdb.com/#check-endpoint
+func checkAbuseIPDB(ctx context.Context, httpClient *http.Client, ip string) (proxyutils.RiskInfo, error) {
+	key, err := riskKey("abuseipdb")
+	if err != nil {
+		return proxyutils.RiskInfo{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.abuseipdb.com/api/v2/check?maxAgeInDays=90&ipAddress="+ip, nil)
+	if err != nil {
+		return proxyutils.RiskInfo{}, err
+	}
+	req.Header.Set("Key", key)
+	req.Header.Set("Accept", "application/json")
+
+	var data struct {
+		Data struct {
+			Score     int    `json:"abuseConfidenceScore"`
+			UsageType string `json:"usageType"`
+			IsTor     bool   `json:"isTor"`
+		} `json:"data"`
+	}
+	if err := getRiskJSON(httpClient, req, &data); err != nil {
+		return proxyutils.RiskInfo{}, err
+	}
+
+	risk := proxyutils.RiskInfo{Score: data.Data.Score}
+	usage := strings.ToLower(data.Data.UsageType)
+	switch {
+	case data.Data.Score >= 50:
+		risk.Category = proxyutils.RiskAbuse
+	case data.Data.IsTor:
+		risk.Category = proxyutils.RiskProxy
+	case strings.Contains(usage, "data center"), strings.Contains(usage, "hosting"):
+		risk.Category = proxyutils.RiskDatacenter
+	case strings.Contains(usage, "isp"):
+		risk.Category = proxyutils.RiskResidential
+	}
+	return risk, nil
+}
+
+// blockEntry 离线黑名单中的一条记录
+type blockEntry struct {
+	prefix   netip.Prefix
+	category string
+}
+
+var (
+	blocklistLock sync.RWMutex
+	blocklist     []blockEntry
+)
+
+// LoadRiskBlocklist 每轮检测前重新读取 ip-risk-blocklist 中的文件
+// 每行一个IP或CIDR，后面可以跟分类(默认abuse)，# 开头为注释
+func LoadRiskBlocklist() {
+	var entries []blockEntry
+	for _, path := range config.GlobalConfig.IPRiskBlocklist {
+		f, err := os.Open(path)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("读取IP黑名单失败: %v", err))
+			continue
 		}
-		var score, rist string
-		{
-			score = strings.TrimSpace(lines[4])
-			tmp := strings.Split(score, ":")
-			score = strings.ReplaceAll(tmp[1], "\"", "")
-			score = strings.ReplaceAll(score, ",", "")
-
-			rist = strings.TrimSpace(lines[5])
-			tmp = strings.Split(rist, ":")
-			rist = strings.ReplaceAll(tmp[1], "\"", "")
-			rist = strings.ReplaceAll(rist, ",", "")
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			prefix, err := netip.ParsePrefix(fields[0])
+			if err != nil {
+				addr, err := netip.ParseAddr(fields[0])
+				if err != nil {
+					slog.Debug(fmt.Sprintf("忽略无效的黑名单记录: %s", fields[0]))
+					continue
+				}
+				prefix = netip.PrefixFrom(addr, addr.BitLen())
+			}
+			entry := blockEntry{prefix: prefix.Masked(), category: proxyutils.RiskAbuse}
+			if len(fields) > 1 {
+				entry.category = strings.ToLower(fields[1])
+			}
+			entries = append(entries, entry)
 		}
+		f.Close()
+	}
+
+	blocklistLock.Lock()
+	blocklist = entries
+	blocklistLock.Unlock()
+}
+
+// CheckBlocklist 查询离线黑名单，命中时分数为100
+func CheckBlocklist(ip string) (proxyutils.RiskInfo, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return proxyutils.RiskInfo{}, false
+	}
+	addr = addr.Unmap()
 
-		if score != "" && rist != "" {
-			// return fmt.Sprintf("%s%% %s", score, rist), nil
-			return fmt.Sprintf("%s%%", score), nil
+	blocklistLock.RLock()
+	defer blocklistLock.RUnlock()
+	for _, e := range blocklist {
+		if e.prefix.Contains(addr) {
+			return proxyutils.RiskInfo{Score: 100, Category: e.category, Source: "blocklist"}, true
 		}
 	}
-	return "", nil
+	return proxyutils.RiskInfo{}, false
 }
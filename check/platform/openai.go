@@ -1,6 +1,7 @@
 package platform
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"strings"
@@ -9,13 +10,13 @@ import (
 // 1.如果全部通过，ChatGPT客户端可正常使用，res.Openai = true，tag为"GPT⁺"
 // 2.如果只通过cookies检测 或 client检测，res.OpenaiWeb = true，tag为"GPT"
 // 经在Windows和ios客户端测试，如果仅通过一项检测，客户端很大概率不能使用，但web端很大概率可以使用。所以如果全部通过添加了一个角标"⁺",保留仅通过一项检测的tag为"GPT",web端用户几乎不需要发现标签变化。
-func CheckOpenAI(httpClient *http.Client) (bool, bool) {
-	return CheckCookies(httpClient), CheckClient(httpClient)
+func CheckOpenAI(ctx context.Context, httpClient *http.Client) (bool, bool) {
+	return CheckCookies(ctx, httpClient), CheckClient(ctx, httpClient)
 }
 
 // 通过检查cookies判断网络访问
-func CheckCookies(httpClient *http.Client) bool {
-	req, err := http.NewRequest("GET", "https://api.openai.com/compliance/cookie_requirements", nil)
+func CheckCookies(ctx context.Context, httpClient *http.Client) bool {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.openai.com/compliance/cookie_requirements", nil)
 	if err != nil {
 		return false
 	}
@@ -39,8 +40,8 @@ func CheckCookies(httpClient *http.Client) bool {
 }
 
 // 通过模拟客户端访问检查app可用性
-func CheckClient(httpClient *http.Client) bool {
-	req, err := http.NewRequest("GET", "https://ios.chat.openai.com", nil)
+func CheckClient(ctx context.Context, httpClient *http.Client) bool {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://ios.chat.openai.com", nil)
 	if err != nil {
 		return false
 	}
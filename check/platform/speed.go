@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -41,17 +42,61 @@ func (r *networkLimitedReader) Read(p []byte) (n int, err error) {
 	return r.reader.Read(p)
 }
 
-func CheckSpeed(ctx context.Context, httpClient *http.Client, bucket *ratelimit.Bucket, bytesCounter *uint64) (int, int64, error) {
+// SpeedResult 下载测速结果
+type SpeedResult struct {
+	Speed int    // 预热后的平均速度(KB/s)
+	Peak  int    // 1秒窗口内的峰值速度(KB/s)
+	Bytes int64  // 网络层实际传输的字节数
+	URL   string // 成功测速的地址
+}
+
+// SpeedTestUrls 返回所有测速地址，speed-test-url 在前，speed-test-urls 依次作为备用
+func SpeedTestUrls() []string {
+	var urls []string
+	if config.GlobalConfig.SpeedTestUrl != "" {
+		urls = append(urls, config.GlobalConfig.SpeedTestUrl)
+	}
+	for _, u := range config.GlobalConfig.SpeedTestUrls {
+		if u != "" && u != config.GlobalConfig.SpeedTestUrl {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// CheckSpeed 依次尝试各个测速地址，直到有一个测速成功
+func CheckSpeed(ctx context.Context, httpClient *http.Client, bucket *ratelimit.Bucket, bytesCounter *uint64) (SpeedResult, error) {
 	// 注意：速度限制在网络层（statsConn）实现，大小限制在应用层基于网络字节计数器实现
 	// - 速度限制：通过 bucket 在 statsConn 中实现（网络层）
 	// - 大小限制：通过 networkLimitedReader 基于网络字节计数器实现（应用层，但限制网络流量）
+	var lastErr error
+	for _, url := range SpeedTestUrls() {
+		select {
+		case <-ctx.Done():
+			return SpeedResult{}, ctx.Err()
+		default:
+		}
+		res, err := checkSpeedURL(ctx, httpClient, bytesCounter, url)
+		if err == nil {
+			return res, nil
+		}
+		slog.Debug(fmt.Sprintf("测速地址失败，尝试下一个: %v", err), "url", url)
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("没有配置测速地址")
+	}
+	return SpeedResult{}, lastErr
+}
 
+// checkSpeedURL 对单个地址并发多个连接下载，预热时间内的数据不计入平均速度
+func checkSpeedURL(ctx context.Context, httpClient *http.Client, bytesCounter *uint64, url string) (SpeedResult, error) {
 	// 单独为测速设置硬性超时时间，确保超时快速返回
-	speedCtx := ctx
+	speedCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	if config.GlobalConfig.DownloadTimeout > 0 {
 		timeout := time.Duration(config.GlobalConfig.DownloadTimeout) * time.Second
-		var cancel context.CancelFunc
-		speedCtx, cancel = context.WithTimeout(ctx, timeout)
+		speedCtx, cancel = context.WithTimeout(speedCtx, timeout)
 		defer cancel()
 	}
 
@@ -63,36 +108,97 @@ func CheckSpeed(ctx context.Context, httpClient *http.Client, bucket *ratelimit.
 		Transport: httpClient.Transport,
 	}
 
-	req, err := http.NewRequest("GET", config.GlobalConfig.SpeedTestUrl, nil)
-	if err != nil {
-		return 0, 0, err
+	// 没有字节计数器时无法限制大小和计算速度，在这里补一个
+	if bytesCounter == nil {
+		bytesCounter = new(uint64)
 	}
-	req.Header.Set("User-Agent", convert.RandUserAgent())
-	req = req.WithContext(speedCtx)
 
-	// 记录测速前的网络传输字节数
-	var startBytes uint64
-	if bytesCounter != nil {
-		startBytes = *bytesCounter
+	// 计算网络层的大小限制，多个连接共享
+	var limitSize uint64
+	if config.GlobalConfig.DownloadMB > 0 {
+		limitSize = uint64(config.GlobalConfig.DownloadMB) * 1024 * 1024
+	}
+
+	streams := config.GlobalConfig.SpeedStreams
+	if streams <= 0 {
+		streams = 1
 	}
+
+	// 记录测速前的网络传输字节数
+	startBytes := atomic.LoadUint64(bytesCounter)
 	startTime := time.Now()
 
-	resp, err := speedClient.Do(req)
-	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-			slog.Debug("测速超时/取消，快速返回", "url", config.GlobalConfig.SpeedTestUrl)
+	var (
+		wg      sync.WaitGroup
+		success atomic.Int32
+		errOnce sync.Once
+		lastErr error
+	)
+	for i := 0; i < streams; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := downloadStream(speedCtx, speedClient, url, bytesCounter, startBytes, limitSize); err != nil {
+				errOnce.Do(func() { lastErr = err })
+				return
+			}
+			success.Add(1)
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	warmupBytes, warmupTime, peak := sampleSpeed(done, bytesCounter, startBytes, startTime)
+
+	actualBytes := int64(atomic.LoadUint64(bytesCounter) - startBytes)
+	if success.Load() == 0 {
+		if lastErr == nil {
+			lastErr = errors.New("测速失败")
+		}
+		if errors.Is(lastErr, context.DeadlineExceeded) || errors.Is(lastErr, context.Canceled) {
+			slog.Debug("测速超时/取消，快速返回", "url", url)
 		}
+		return SpeedResult{}, lastErr
+	}
+
+	// 计算速度（KB/s），使用实际网络传输的字节数，扣除预热阶段
+	measured := actualBytes - int64(warmupBytes)
+	duration := time.Since(startTime.Add(warmupTime)).Milliseconds()
+	if warmupTime == 0 || measured <= 0 || duration <= 0 {
+		// 预热期内就结束了，退化为全程平均
+		measured = actualBytes
+		duration = time.Since(startTime).Milliseconds()
+	}
+	if duration == 0 {
+		duration = 1 // 避免除以零
+	}
+	speed := int(float64(measured) / 1024 * 1000 / float64(duration))
+	if peak < speed {
+		peak = speed
+	}
+
+	return SpeedResult{Speed: speed, Peak: peak, Bytes: actualBytes, URL: url}, nil
+}
+
+// downloadStream 单个连接的下载
+func downloadStream(ctx context.Context, client *http.Client, url string, bytesCounter *uint64, startBytes, limit uint64) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", convert.RandUserAgent())
+
+	resp, err := client.Do(req)
+	if err != nil {
 		slog.Debug(fmt.Sprintf("测速请求失败: %v", err))
-		return 0, 0, err
+		return err
 	}
 	defer resp.Body.Close()
-
-	// 计算网络层的大小限制
-	var limitSize uint64
-	if config.GlobalConfig.DownloadMB > 0 {
-		limitSize = uint64(config.GlobalConfig.DownloadMB) * 1024 * 1024
-	} else {
-		limitSize = 0 // 不限制
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("测速地址返回状态码: %d", resp.StatusCode)
 	}
 
 	// 使用 networkLimitedReader 包装响应体，基于网络字节计数器限制大小
@@ -100,34 +206,123 @@ func CheckSpeed(ctx context.Context, httpClient *http.Client, bucket *ratelimit.
 		reader:       resp.Body,
 		bytesCounter: bytesCounter,
 		startBytes:   startBytes,
-		limit:        limitSize,
+		limit:        limit,
 	}
 
 	// 读取所有数据
 	totalBytes, err := io.Copy(io.Discard, limitedReader)
-	// io.EOF 是正常的（达到限制），其他错误才需要关注
+	// io.EOF 是正常的（达到限制），超时前已经读到的数据也算有效，其他错误才需要关注
 	if err != nil && err != io.EOF && totalBytes == 0 {
 		slog.Debug(fmt.Sprintf("totalBytes: %d, 读取数据时发生错误: %v", totalBytes, err))
+		return err
+	}
+	return nil
+}
+
+// sampleSpeed 每 250ms 采样一次字节数直到 done 关闭
+// 返回预热结束时已传输的字节数和预热实际时长，以及预热后 1 秒窗口内的峰值速度(KB/s)
+func sampleSpeed(done <-chan struct{}, bytesCounter *uint64, startBytes uint64, startTime time.Time) (uint64, time.Duration, int) {
+	const (
+		interval = 250 * time.Millisecond
+		window   = 4 // 1 秒
+	)
+	warmup := time.Duration(config.GlobalConfig.SpeedWarmupMs) * time.Millisecond
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		samples     []uint64
+		warmupBytes uint64
+		warmupTime  time.Duration
+		peak        int
+	)
+	for {
+		select {
+		case <-done:
+			return warmupBytes, warmupTime, peak
+		case <-ticker.C:
+		}
+		current := atomic.LoadUint64(bytesCounter) - startBytes
+		elapsed := time.Since(startTime)
+		if elapsed < warmup {
+			continue
+		}
+		if warmupTime == 0 && warmup > 0 {
+			warmupBytes, warmupTime = current, elapsed
+		}
+		samples = append(samples, current)
+		if n := len(samples); n > window {
+			rate := int(float64(samples[n-1]-samples[n-1-window]) / 1024)
+			if rate > peak {
+				peak = rate
+			}
+		}
+	}
+}
+
+// zeroReader 上传测速用的数据源
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// CheckUpload 向 upload-test-url POST upload-mb 大小的数据，按网络层实际写出的字节数计算上传速度(KB/s)
+// 限速同样在 statsConn 中完成，与下载共用一个限速桶
+func CheckUpload(ctx context.Context, httpClient *http.Client, bytesCounter *uint64) (int, int64, error) {
+	uploadCtx := ctx
+	if config.GlobalConfig.DownloadTimeout > 0 {
+		var cancel context.CancelFunc
+		uploadCtx, cancel = context.WithTimeout(ctx, time.Duration(config.GlobalConfig.DownloadTimeout)*time.Second)
+		defer cancel()
+	}
+
+	size := int64(config.GlobalConfig.UploadMB) * 1024 * 1024
+	if size <= 0 {
+		size = 5 * 1024 * 1024
+	}
+	req, err := http.NewRequestWithContext(uploadCtx, "POST", config.GlobalConfig.UploadTestUrl, io.LimitReader(zeroReader{}, size))
+	if err != nil {
 		return 0, 0, err
 	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("User-Agent", convert.RandUserAgent())
 
-	// 计算下载时间（毫秒）
-	duration := time.Since(startTime).Milliseconds()
-	if duration == 0 {
-		duration = 1 // 避免除以零
+	uploadClient := &http.Client{
+		Transport: httpClient.Transport,
 	}
 
-	// 计算实际网络传输的字节数（压缩数据）
+	var startBytes uint64
+	if bytesCounter != nil {
+		startBytes = atomic.LoadUint64(bytesCounter)
+	}
+	startTime := time.Now()
+
+	resp, err := uploadClient.Do(req)
+	// 超时前写出的数据也可以用来估算速度，所以只有完全没有写出时才视为失败
 	var actualBytes int64
 	if bytesCounter != nil {
-		actualBytes = int64(*bytesCounter - startBytes)
+		actualBytes = int64(atomic.LoadUint64(bytesCounter) - startBytes)
+	}
+	duration := time.Since(startTime).Milliseconds()
+	if duration == 0 {
+		duration = 1
+	}
+	if err != nil {
+		if !errors.Is(err, context.DeadlineExceeded) || actualBytes < 1024*1024 {
+			slog.Debug(fmt.Sprintf("上传测速失败: %v", err))
+			return 0, 0, err
+		}
 	} else {
-		// 如果没有字节计数器，无法获取准确数据
-		actualBytes = 0
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return 0, 0, fmt.Errorf("上传测速返回状态码: %d", resp.StatusCode)
+		}
 	}
 
-	// 计算速度（KB/s），使用实际网络传输的字节数
 	speed := int(float64(actualBytes) / 1024 * 1000 / float64(duration))
-
 	return speed, actualBytes, nil
 }
@@ -0,0 +1,83 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/beck-8/subs-check/check/platform"
+	"github.com/beck-8/subs-check/config"
+)
+
+// QuickCheck 只对已发布的节点做存活检测，返回仍然可用的节点
+// 不拉取订阅，也不测速和检测流媒体，节点名称保持不变
+// 被 Cancel 强制关闭时返回原来的节点和 ErrCancelled
+func QuickCheck(ctx context.Context, results []Result) ([]Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	setRunCancel(cancel)
+	defer setRunCancel(nil)
+
+	tasks := make(chan int)
+	alive := make([]bool, len(results))
+	latency := make([]int, len(results))
+
+	go func() {
+		defer close(tasks)
+		for i := range results {
+			select {
+			case tasks <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	threads := stageThreads(config.GlobalConfig.AliveConcurrent, len(results))
+	runStage(threads, func() {
+		for i := range tasks {
+			// 失败时再试一次，避免偶发超时把好节点移除
+			for range 2 {
+				if ms, ok := quickAlive(ctx, results[i].Proxy); ok {
+					alive[i], latency[i] = true, ms
+					break
+				}
+			}
+		}
+	})
+
+	// 没有检测完的节点不能判断为失效
+	if ctx.Err() != nil {
+		return results, ErrCancelled
+	}
+
+	kept := make([]Result, 0, len(results))
+	for i, result := range results {
+		if alive[i] {
+			result.Latency = latency[i]
+			kept = append(kept, result)
+		} else {
+			slog.Debug(fmt.Sprintf("快速复查节点失效: %v", result.Proxy["name"]))
+		}
+	}
+	return kept, nil
+}
+
+func quickAlive(ctx context.Context, proxy map[string]any) (int, bool) {
+	ctx, cancel := context.WithTimeout(ctx, proxyTimeout())
+	defer cancel()
+
+	httpClient := CreateClient(ctx, proxy)
+	if httpClient == nil {
+		return 0, false
+	}
+	defer httpClient.Close()
+
+	start := time.Now()
+	ok, err := platform.CheckAlive(ctx, httpClient.Client)
+	if err != nil || !ok {
+		return 0, false
+	}
+	return int(time.Since(start).Milliseconds()), true
+}
@@ -0,0 +1,119 @@
+package check
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+// quota 按订阅、国家、协议限制可用节点数量，并为 min-per-country 中的国家预留名额
+type quota struct {
+	mu        sync.Mutex
+	admitted  int
+	subs      map[string]int
+	countries map[string]int
+	protocols map[string]int
+	overQuota []Result // 检测通过但超出配额的节点，只用于记录节点历史
+}
+
+func newQuota() *quota {
+	return &quota{
+		subs:      make(map[string]int),
+		countries: make(map[string]int),
+		protocols: make(map[string]int),
+	}
+}
+
+func quotaKeys(res *Result) (sub, country, protocol string) {
+	sub, _ = res.Proxy["sub_url"].(string)
+	protocol, _ = res.Proxy["type"].(string)
+	return sub, strings.ToUpper(res.Country), strings.ToLower(protocol)
+}
+
+// add 计入一个可用节点，调用方需持有锁
+func (q *quota) add(res *Result) {
+	sub, country, protocol := quotaKeys(res)
+	q.admitted++
+	q.subs[sub]++
+	q.countries[country]++
+	q.protocols[protocol]++
+}
+
+// remove 移除一个已计入的节点，调用方需持有锁
+func (q *quota) remove(res *Result) {
+	sub, country, protocol := quotaKeys(res)
+	q.admitted--
+	q.subs[sub]--
+	q.countries[country]--
+	q.protocols[protocol]--
+}
+
+// deficit 还没满足 min-per-country 的节点数，调用方需持有锁
+func (q *quota) deficit() int {
+	n := 0
+	for country, want := range config.GlobalConfig.MinPerCountry {
+		if have := q.countries[strings.ToUpper(country)]; have < want {
+			n += want - have
+		}
+	}
+	return n
+}
+
+// needed 该国家是否还没满足 min-per-country，调用方需持有锁
+func (q *quota) needed(country string) bool {
+	for c, want := range config.GlobalConfig.MinPerCountry {
+		if strings.EqualFold(c, country) {
+			return q.countries[country] < want
+		}
+	}
+	return false
+}
+
+// admit 判断检测通过的节点是否在配额内，在配额内时计入
+// 同时配置了 success-limit 和 min-per-country 时为缺额的国家预留名额，其他节点只能使用剩余名额
+func (q *quota) admit(res *Result) bool {
+	return q.replace(res, nil)
+}
+
+// replace 用 res 替换已计入的 victim，res 不在配额内时 victim 保持计入；victim 为 nil 时同 admit
+func (q *quota) replace(res, victim *Result) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if victim != nil {
+		q.remove(victim)
+	}
+	sub, country, protocol := quotaKeys(res)
+	reason := ""
+	switch {
+	case config.GlobalConfig.MaxPerSub > 0 && sub != "" && q.subs[sub] >= config.GlobalConfig.MaxPerSub:
+		reason = "订阅"
+	case config.GlobalConfig.MaxPerProtocol > 0 && q.protocols[protocol] >= config.GlobalConfig.MaxPerProtocol:
+		reason = "协议"
+	case config.GlobalConfig.MaxPerCountry > 0 && country != "" && q.countries[country] >= config.GlobalConfig.MaxPerCountry:
+		reason = "国家"
+	case config.GlobalConfig.SuccessLimit > 0 && len(config.GlobalConfig.MinPerCountry) > 0 && !q.needed(country) &&
+		q.admitted+q.deficit() >= int(config.GlobalConfig.SuccessLimit):
+		reason = "预留名额"
+	}
+	if reason != "" {
+		slog.Debug(fmt.Sprintf("超出%s配额，丢弃: %v", reason, res.Proxy["name"]), "sub", sub, "country", country, "type", protocol)
+		q.overQuota = append(q.overQuota, *res)
+		if victim != nil {
+			q.add(victim)
+		}
+		return false
+	}
+	q.add(res)
+	return true
+}
+
+// full 是否达到 success-limit 且所有国家都满足了 min-per-country
+func (q *quota) full() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.admitted >= int(config.GlobalConfig.SuccessLimit) && q.deficit() == 0
+}
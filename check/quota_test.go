@@ -0,0 +1,112 @@
+package check
+
+import (
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+func TestQuotaAdmit(t *testing.T) {
+	node := func(sub, country, typ string) Result {
+		return Result{Proxy: map[string]any{"name": sub + country + typ, "sub_url": sub, "type": typ}, Country: country}
+	}
+	tests := []struct {
+		name    string
+		setup   func(c *config.Config)
+		results []Result
+		want    []bool
+		full    bool
+	}{
+		{
+			name:    "max per sub",
+			setup:   func(c *config.Config) { c.MaxPerSub = 1 },
+			results: []Result{node("a", "HK", "ss"), node("a", "US", "ss"), node("b", "US", "ss")},
+			want:    []bool{true, false, true},
+		},
+		{
+			name:    "max per protocol",
+			setup:   func(c *config.Config) { c.MaxPerProtocol = 2 },
+			results: []Result{node("a", "HK", "ss"), node("a", "HK", "SS"), node("a", "HK", "ss"), node("a", "HK", "vmess")},
+			want:    []bool{true, true, false, true},
+		},
+		{
+			name:    "max per country ignores unknown",
+			setup:   func(c *config.Config) { c.MaxPerCountry = 1 },
+			results: []Result{node("a", "hk", "ss"), node("a", "HK", "ss"), node("a", "", "ss"), node("a", "", "ss")},
+			want:    []bool{true, false, true, true},
+		},
+		{
+			name: "reserve for min per country",
+			setup: func(c *config.Config) {
+				c.SuccessLimit = 3
+				c.MinPerCountry = map[string]int{"jp": 1}
+			},
+			results: []Result{node("a", "HK", "ss"), node("a", "HK", "ss"), node("a", "HK", "ss"), node("a", "JP", "ss")},
+			want:    []bool{true, true, false, true},
+			full:    true,
+		},
+		{
+			name: "full once min per country met",
+			setup: func(c *config.Config) {
+				c.SuccessLimit = 1
+				c.MinPerCountry = map[string]int{"JP": 1}
+			},
+			results: []Result{node("a", "JP", "ss")},
+			want:    []bool{true},
+			full:    true,
+		},
+		{
+			name: "success limit reached",
+			setup: func(c *config.Config) {
+				c.SuccessLimit = 2
+			},
+			results: []Result{node("a", "HK", "ss"), node("a", "US", "ss")},
+			want:    []bool{true, true},
+			full:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := *config.GlobalConfig
+			defer func() { *config.GlobalConfig = cfg }()
+			tt.setup(config.GlobalConfig)
+
+			q := newQuota()
+			for i := range tt.results {
+				if got := q.admit(&tt.results[i]); got != tt.want[i] {
+					t.Errorf("admit(%v) = %v, want %v", tt.results[i].Proxy["name"], got, tt.want[i])
+				}
+			}
+			// full 只在配置了 success-limit 时使用
+			if got := q.full(); config.GlobalConfig.SuccessLimit > 0 && got != tt.full {
+				t.Errorf("full() = %v, want %v", got, tt.full)
+			}
+			rejected := 0
+			for _, ok := range tt.want {
+				if !ok {
+					rejected++
+				}
+			}
+			if len(q.overQuota) != rejected {
+				t.Errorf("overQuota = %d, want %d", len(q.overQuota), rejected)
+			}
+		})
+	}
+}
+
+func TestQuotaFullWaitsForCountry(t *testing.T) {
+	cfg := *config.GlobalConfig
+	defer func() { *config.GlobalConfig = cfg }()
+	config.GlobalConfig.SuccessLimit = 1
+	config.GlobalConfig.MinPerCountry = map[string]int{"JP": 1}
+
+	q := newQuota()
+	hk := Result{Proxy: map[string]any{"name": "hk"}, Country: "HK"}
+	// 名额全部预留给 JP，HK 节点不能进入
+	if q.admit(&hk) {
+		t.Error("admit(HK) = true, want false while JP is missing")
+	}
+	if q.full() {
+		t.Error("full() = true before JP is met")
+	}
+}
@@ -0,0 +1,38 @@
+package check
+
+import (
+	"sync"
+	"time"
+
+	proxyutils "github.com/beck-8/subs-check/proxy"
+)
+
+// Report 单次检测的统计报告
+type Report struct {
+	StartTime  time.Time              `json:"start_time"`
+	EndTime    time.Time              `json:"end_time"`
+	Fetched    int                    `json:"fetched"`
+	Dedup      proxyutils.DedupReport `json:"dedup"`
+	DNSMerged  int                    `json:"dns_merged"`
+	ExitGroups []ExitGroup            `json:"exit_groups"`
+	Available  int                    `json:"available"`
+	Cancelled  bool                   `json:"cancelled"` // 被强制关闭，结果只包含已完成检测的节点
+}
+
+var (
+	lastReport Report
+	reportLock sync.RWMutex
+)
+
+// LastReport 返回最近一次完成的检测报告
+func LastReport() Report {
+	reportLock.RLock()
+	defer reportLock.RUnlock()
+	return lastReport
+}
+
+func setLastReport(report Report) {
+	reportLock.Lock()
+	defer reportLock.Unlock()
+	lastReport = report
+}
@@ -0,0 +1,145 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/metacubex/mihomo/constant"
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultUDPDNSTarget  = "1.1.1.1:53"
+	defaultUDPSTUNTarget = "stun.cloudflare.com:3478"
+	stunMagicCookie      = 0x2112A442
+)
+
+// CheckUDP 通过节点发送一个 UDP 请求(DNS 查询或 STUN binding)，返回往返耗时(ms)
+func (pc *ProxyClient) CheckUDP(ctx context.Context) (int, error) {
+	if pc.proxy == nil || !pc.proxy.SupportUDP() {
+		return 0, fmt.Errorf("节点未开启UDP")
+	}
+
+	stun := strings.EqualFold(config.GlobalConfig.UDPCheckMode, "stun")
+	target := config.GlobalConfig.UDPCheckTarget
+	if target == "" {
+		target = defaultUDPDNSTarget
+		if stun {
+			target = defaultUDPSTUNTarget
+		}
+	}
+	addr, err := resolveUDPTarget(ctx, target)
+	if err != nil {
+		return 0, err
+	}
+
+	timeout := time.Duration(config.GlobalConfig.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := pc.proxy.ListenPacketContext(ctx, &constant.Metadata{
+		NetWork: constant.UDP,
+		DstIP:   addr.Addr(),
+		DstPort: addr.Port(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var (
+		req   []byte
+		check func(resp []byte) bool
+	)
+	if stun {
+		req, check = stunRequest()
+	} else {
+		req, check, err = dnsRequest()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	udpAddr := net.UDPAddrFromAddrPort(addr)
+	buf := make([]byte, 2048)
+	start := time.Now()
+	// UDP 可能丢包，超时前每秒重发一次
+	for {
+		if _, err := conn.WriteTo(req, udpAddr); err != nil {
+			return 0, err
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err == nil && check(buf[:n]) {
+			return int(time.Since(start).Milliseconds()), nil
+		}
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = fmt.Errorf("UDP响应无效")
+			}
+			return 0, err
+		default:
+		}
+	}
+}
+
+func resolveUDPTarget(ctx context.Context, target string) (netip.AddrPort, error) {
+	if addr, err := netip.ParseAddrPort(target); err == nil {
+		return addr, nil
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	p, err := net.LookupPort("udp", port)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip4", host)
+	if err != nil || len(ips) == 0 {
+		return netip.AddrPort{}, fmt.Errorf("解析UDP测试地址失败: %s: %v", host, err)
+	}
+	return netip.AddrPortFrom(ips[0], uint16(p)), nil
+}
+
+// dnsRequest 构造一个 A 记录查询，响应 ID 一致即视为成功
+func dnsRequest() ([]byte, func([]byte) bool, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion("www.google.com.", dns.TypeA)
+	req, err := msg.Pack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return req, func(resp []byte) bool {
+		var r dns.Msg
+		return r.Unpack(resp) == nil && r.Id == msg.Id && r.Response
+	}, nil
+}
+
+// stunRequest 构造 RFC 5389 binding request，响应类型和事务ID一致即视为成功
+func stunRequest() ([]byte, func([]byte) bool) {
+	req := make([]byte, 20)
+	binary.BigEndian.PutUint16(req[0:2], 0x0001)
+	binary.BigEndian.PutUint32(req[4:8], stunMagicCookie)
+	rand.Read(req[8:20])
+	return req, func(resp []byte) bool {
+		return len(resp) >= 20 &&
+			binary.BigEndian.Uint16(resp[0:2]) == 0x0101 &&
+			bytes.Equal(resp[8:20], req[8:20])
+	}
+}
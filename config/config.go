@@ -6,10 +6,23 @@ type Config struct {
 	PrintProgress        bool     `yaml:"print-progress"`
 	ManualTriggerOnly    bool     `yaml:"manual-trigger-only"`
 	Concurrent           int      `yaml:"concurrent"`
+	AliveConcurrent      int      `yaml:"alive-concurrent"`
+	SpeedConcurrent      int      `yaml:"speed-concurrent"`
+	MediaConcurrent      int      `yaml:"media-concurrent"`
+	AdaptiveConcurrency  bool     `yaml:"adaptive-concurrency"`
+	MinConcurrent        int      `yaml:"min-concurrent"`
+	MaxConcurrent        int      `yaml:"max-concurrent"`
+	AdaptiveMemoryLimit  string   `yaml:"adaptive-memory-limit"`
+	CheckpointInterval   int      `yaml:"checkpoint-interval"`
+	SavePartialOnClose   bool     `yaml:"save-partial-on-close"`
 	CheckInterval        int      `yaml:"check-interval"`
+	QuickCheckInterval   int      `yaml:"quick-check-interval"`
 	CronExpression       string   `yaml:"cron-expression"`
 	AliveTestUrl         string   `yaml:"alive-test-url"`
 	SpeedTestUrl         string   `yaml:"speed-test-url"`
+	SpeedTestUrls        []string `yaml:"speed-test-urls"`
+	SpeedStreams         int      `yaml:"speed-test-streams"`
+	SpeedWarmupMs        int      `yaml:"speed-test-warmup"`
 	DownloadTimeout      int      `yaml:"download-timeout"`
 	DownloadMB           int      `yaml:"download-mb"`
 	TotalSpeedLimit      int      `yaml:"total-speed-limit"`
@@ -37,12 +50,22 @@ type Config struct {
 	SubUrlsGetUA         string   `yaml:"sub-urls-get-ua"`
 	SubUrlsRemote        []string `yaml:"sub-urls-remote"`
 	SubUrls              []string `yaml:"sub-urls"`
+	ProviderMaxDepth     int      `yaml:"provider-max-depth"`
+	ProviderMaxCount     int      `yaml:"provider-max-count"`
+	ProviderBaseDir      string   `yaml:"provider-base-dir"`
 	SuccessRate          float32  `yaml:"success-rate"`
+	SubQuarantineRuns    int      `yaml:"sub-quarantine-runs"`
+	SubProbeRuns         int      `yaml:"sub-quarantine-probe-runs"`
+	StateDir             string   `yaml:"state-dir"`
 	MihomoApiUrl         string   `yaml:"mihomo-api-url"`
 	MihomoApiSecret      string   `yaml:"mihomo-api-secret"`
 	ListenPort           string   `yaml:"listen-port"`
 	RenameNode           bool     `yaml:"rename-node"`
 	KeepSuccessProxies   bool     `yaml:"keep-success-proxies"`
+	ExitIPDedup          int      `yaml:"exit-ip-dedup"`
+	DNSDedup             bool     `yaml:"dns-dedup"`
+	DNSDedupServer       string   `yaml:"dns-dedup-server"`
+	DNSDedupConcurrent   int      `yaml:"dns-dedup-concurrent"`
 	OutputDir            string   `yaml:"output-dir"`
 	AppriseApiServer     string   `yaml:"apprise-api-server"`
 	RecipientUrl         []string `yaml:"recipient-url"`
@@ -56,13 +79,91 @@ type Config struct {
 	MediaCheck           bool     `yaml:"media-check"`
 	Platforms            []string `yaml:"platforms"`
 	SuccessLimit         int32    `yaml:"success-limit"`
+	PriorityOrder        bool     `yaml:"priority-order"`
 	NodePrefix           string   `yaml:"node-prefix"`
+	NameTemplate         string   `yaml:"name-template"`
+	NameLocale           string   `yaml:"name-locale"`
+	NameFlag             bool     `yaml:"name-flag"`
+	RenameGraceHours     int      `yaml:"rename-grace-hours"`
 	NodeType             []string `yaml:"node-type"`
 	EnableWebUI          bool     `yaml:"enable-web-ui"`
 	APIKey               string   `yaml:"api-key"`
 	GithubProxy          string   `yaml:"github-proxy"`
 	Proxy                string   `yaml:"proxy"`
 	CallbackScript       string   `yaml:"callback-script"`
+
+	CountryAlias map[string]string `yaml:"country-alias"`
+
+	// 本地 GeoIP 数据库
+	MMDBCountry     string `yaml:"mmdb-country"`
+	MMDBCity        string `yaml:"mmdb-city"`
+	MMDBASN         string `yaml:"mmdb-asn"`
+	MMDBCountryUrl  string `yaml:"mmdb-country-url"`
+	MMDBCityUrl     string `yaml:"mmdb-city-url"`
+	MMDBASNUrl      string `yaml:"mmdb-asn-url"`
+	MMDBUpdateHours int    `yaml:"mmdb-update-hours"`
+	IPEchoUrl       string `yaml:"ip-echo-url"`
+
+	// 出口IP位置查询接口
+	GeoProviders []string      `yaml:"geo-providers"`
+	GeoRace      bool          `yaml:"geo-race"`
+	GeoCustom    []GeoProvider `yaml:"geo-custom-providers"`
+	IPCacheTTL   int           `yaml:"ip-cache-ttl"`
+
+	// IP风险查询
+	IPRiskProviders []string          `yaml:"ip-risk-providers"`
+	IPRiskKeys      map[string]string `yaml:"ip-risk-keys"`
+	IPRiskBlocklist []string          `yaml:"ip-risk-blocklist"`
+	MaxIPRisk       int               `yaml:"max-ip-risk"`
+
+	// 出口IP类型
+	IPTypeASN  map[string][]string `yaml:"ip-type-asn"`
+	IPTypeCIDR map[string][]string `yaml:"ip-type-cidr"`
+	IPTypeTag  bool                `yaml:"ip-type-tag"`
+
+	EntryGeo       bool `yaml:"entry-geo"`
+	GeoMismatchTag bool `yaml:"geo-mismatch-tag"`
+
+	UDPCheck       bool   `yaml:"udp-check"`
+	UDPCheckMode   string `yaml:"udp-check-mode"`
+	UDPCheckTarget string `yaml:"udp-check-target"`
+
+	UploadTestUrl  string `yaml:"upload-test-url"`
+	UploadMB       int    `yaml:"upload-mb"`
+	MinUploadSpeed int    `yaml:"min-upload-speed"`
+
+	OutputCategories []OutputCategory `yaml:"output-categories"`
+
+	// 可用节点配额
+	MaxPerSub      int            `yaml:"max-per-sub"`
+	MaxPerCountry  int            `yaml:"max-per-country"`
+	MaxPerProtocol int            `yaml:"max-per-protocol"`
+	MinPerCountry  map[string]int `yaml:"min-per-country"`
+}
+
+// OutputCategory 额外输出的节点文件，按条件筛选检测结果
+type OutputCategory struct {
+	Name      string   `yaml:"name"`      // 文件名，如 residential.yaml
+	IPTypes   []string `yaml:"ip-types"`  // 只保留这些出口类型，为空不限制
+	Countries []string `yaml:"countries"` // 只保留这些国家代码，为空不限制
+	Regions   []string `yaml:"regions"`   // 只保留这些省/州，为空不限制
+	Cities    []string `yaml:"cities"`    // 只保留这些城市，为空不限制
+	GroupBy   string   `yaml:"group-by"`  // 按 country/region/city 拆分成多个文件
+}
+
+// GeoProvider 自定义的 json 位置查询接口，路径以 . 分隔，如 data.location.country_code
+type GeoProvider struct {
+	Name        string `yaml:"name"`
+	Url         string `yaml:"url"`
+	UserAgent   string `yaml:"user-agent"`
+	IPPath      string `yaml:"ip-path"`
+	CountryPath string `yaml:"country-path"`
+	RegionPath  string `yaml:"region-path"`
+	CityPath    string `yaml:"city-path"`
+	LatPath     string `yaml:"lat-path"`
+	LonPath     string `yaml:"lon-path"`
+	ASNPath     string `yaml:"asn-path"`
+	ISPPath     string `yaml:"isp-path"`
 }
 
 var GlobalConfig = &Config{
@@ -72,9 +173,19 @@ var GlobalConfig = &Config{
 	MihomoOverwriteUrl: "http://127.0.0.1:8199/sub/ACL4SSR_Online_Full.yaml",
 	Platforms:          []string{"openai", "youtube", "netflix", "disney", "gemini", "iprisk"},
 	DownloadMB:         20,
+	UploadMB:           5,
 	AliveTestUrl:       "http://gstatic.com/generate_204",
 	SubUrlsGetUA:       "clash.meta (https://github.com/beck-8/subs-check)",
 	ManualTriggerOnly:  false,
+	ProviderMaxDepth:   2,
+	ProviderMaxCount:   20,
+	SubProbeRuns:       6,
+	NameLocale:         "zh",
+	NameFlag:           true,
+	RenameGraceHours:   24,
+	MMDBUpdateHours:    168,
+	IPCacheTTL:         6,
+	SavePartialOnClose: true,
 }
 
 //go:embed config.example.yaml
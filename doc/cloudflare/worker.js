@@ -100,6 +100,17 @@ const routeHandlers = {
     },
     async speedtest(request, url, env) {
         try {
+            // 上传测速：读取完请求体后返回收到的字节数
+            if (request.method === 'POST') {
+                const data = await request.arrayBuffer();
+                return new Response(JSON.stringify({ bytes: data.byteLength }), {
+                    headers: {
+                        'Access-Control-Allow-Origin': '*',
+                        'Content-Type': 'application/json'
+                    }
+                });
+            }
+
             const bytes = url.searchParams.get('bytes');
             if (!bytes) {
                 return handleError('请提供测试大小(bytes)', 400);
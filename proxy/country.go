@@ -0,0 +1,640 @@
+package proxies
+
+import (
+	"strings"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+var (
+	// 英文国家名称
+	countryNamesEN = map[string]string{
+		"AD": "Andorra",
+		"AE": "UAE",
+		"AF": "Afghanistan",
+		"AG": "Antigua and Barbuda",
+		"AI": "Anguilla",
+		"AL": "Albania",
+		"AM": "Armenia",
+		"AO": "Angola",
+		"AQ": "Antarctica",
+		"AR": "Argentina",
+		"AS": "American Samoa",
+		"AT": "Austria",
+		"AU": "Australia",
+		"AW": "Aruba",
+		"AX": "Åland Islands",
+		"AZ": "Azerbaijan",
+		"BA": "Bosnia and Herzegovina",
+		"BB": "Barbados",
+		"BD": "Bangladesh",
+		"BE": "Belgium",
+		"BF": "Burkina Faso",
+		"BG": "Bulgaria",
+		"BH": "Bahrain",
+		"BI": "Burundi",
+		"BJ": "Benin",
+		"BL": "Saint Barthélemy",
+		"BM": "Bermuda",
+		"BN": "Brunei",
+		"BO": "Bolivia",
+		"BQ": "Caribbean Netherlands",
+		"BR": "Brazil",
+		"BS": "Bahamas",
+		"BT": "Bhutan",
+		"BV": "Bouvet Island",
+		"BW": "Botswana",
+		"BY": "Belarus",
+		"BZ": "Belize",
+		"CA": "Canada",
+		"CC": "Cocos Islands",
+		"CD": "DR Congo",
+		"CF": "Central African Republic",
+		"CG": "Congo",
+		"CH": "Switzerland",
+		"CI": "Côte d'Ivoire",
+		"CK": "Cook Islands",
+		"CL": "Chile",
+		"CM": "Cameroon",
+		"CN": "China",
+		"CO": "Colombia",
+		"CR": "Costa Rica",
+		"CU": "Cuba",
+		"CV": "Cape Verde",
+		"CW": "Curaçao",
+		"CX": "Christmas Island",
+		"CY": "Cyprus",
+		"CZ": "Czechia",
+		"DE": "Germany",
+		"DJ": "Djibouti",
+		"DK": "Denmark",
+		"DM": "Dominica",
+		"DO": "Dominican Republic",
+		"DZ": "Algeria",
+		"EC": "Ecuador",
+		"EE": "Estonia",
+		"EG": "Egypt",
+		"EH": "Western Sahara",
+		"ER": "Eritrea",
+		"ES": "Spain",
+		"ET": "Ethiopia",
+		"FI": "Finland",
+		"FJ": "Fiji",
+		"FK": "Falkland Islands",
+		"FM": "Micronesia",
+		"FO": "Faroe Islands",
+		"FR": "France",
+		"GA": "Gabon",
+		"GB": "United Kingdom",
+		"GD": "Grenada",
+		"GE": "Georgia",
+		"GF": "French Guiana",
+		"GG": "Guernsey",
+		"GH": "Ghana",
+		"GI": "Gibraltar",
+		"GL": "Greenland",
+		"GM": "Gambia",
+		"GN": "Guinea",
+		"GP": "Guadeloupe",
+		"GQ": "Equatorial Guinea",
+		"GR": "Greece",
+		"GS": "South Georgia",
+		"GT": "Guatemala",
+		"GU": "Guam",
+		"GW": "Guinea-Bissau",
+		"GY": "Guyana",
+		"HK": "Hong Kong",
+		"HM": "Heard and McDonald Islands",
+		"HN": "Honduras",
+		"HR": "Croatia",
+		"HT": "Haiti",
+		"HU": "Hungary",
+		"ID": "Indonesia",
+		"IE": "Ireland",
+		"IL": "Israel",
+		"IM": "Isle of Man",
+		"IN": "India",
+		"IO": "British Indian Ocean Territory",
+		"IQ": "Iraq",
+		"IR": "Iran",
+		"IS": "Iceland",
+		"IT": "Italy",
+		"JE": "Jersey",
+		"JM": "Jamaica",
+		"JO": "Jordan",
+		"JP": "Japan",
+		"KE": "Kenya",
+		"KG": "Kyrgyzstan",
+		"KH": "Cambodia",
+		"KI": "Kiribati",
+		"KM": "Comoros",
+		"KN": "Saint Kitts and Nevis",
+		"KP": "North Korea",
+		"KR": "South Korea",
+		"KW": "Kuwait",
+		"KY": "Cayman Islands",
+		"KZ": "Kazakhstan",
+		"LA": "Laos",
+		"LB": "Lebanon",
+		"LC": "Saint Lucia",
+		"LI": "Liechtenstein",
+		"LK": "Sri Lanka",
+		"LR": "Liberia",
+		"LS": "Lesotho",
+		"LT": "Lithuania",
+		"LU": "Luxembourg",
+		"LV": "Latvia",
+		"LY": "Libya",
+		"MA": "Morocco",
+		"MC": "Monaco",
+		"MD": "Moldova",
+		"ME": "Montenegro",
+		"MF": "Saint Martin",
+		"MG": "Madagascar",
+		"MH": "Marshall Islands",
+		"MK": "North Macedonia",
+		"ML": "Mali",
+		"MM": "Myanmar",
+		"MN": "Mongolia",
+		"MO": "Macao",
+		"MP": "Northern Mariana Islands",
+		"MQ": "Martinique",
+		"MR": "Mauritania",
+		"MS": "Montserrat",
+		"MT": "Malta",
+		"MU": "Mauritius",
+		"MV": "Maldives",
+		"MW": "Malawi",
+		"MX": "Mexico",
+		"MY": "Malaysia",
+		"MZ": "Mozambique",
+		"NA": "Namibia",
+		"NC": "New Caledonia",
+		"NE": "Niger",
+		"NF": "Norfolk Island",
+		"NG": "Nigeria",
+		"NI": "Nicaragua",
+		"NL": "Netherlands",
+		"NO": "Norway",
+		"NP": "Nepal",
+		"NR": "Nauru",
+		"NU": "Niue",
+		"NZ": "New Zealand",
+		"OM": "Oman",
+		"PA": "Panama",
+		"PE": "Peru",
+		"PF": "French Polynesia",
+		"PG": "Papua New Guinea",
+		"PH": "Philippines",
+		"PK": "Pakistan",
+		"PL": "Poland",
+		"PM": "Saint Pierre and Miquelon",
+		"PN": "Pitcairn Islands",
+		"PR": "Puerto Rico",
+		"PS": "Palestine",
+		"PT": "Portugal",
+		"PW": "Palau",
+		"PY": "Paraguay",
+		"QA": "Qatar",
+		"RE": "Réunion",
+		"RO": "Romania",
+		"RS": "Serbia",
+		"RU": "Russia",
+		"RW": "Rwanda",
+		"SA": "Saudi Arabia",
+		"SB": "Solomon Islands",
+		"SC": "Seychelles",
+		"SD": "Sudan",
+		"SE": "Sweden",
+		"SG": "Singapore",
+		"SH": "Saint Helena",
+		"SI": "Slovenia",
+		"SJ": "Svalbard and Jan Mayen",
+		"SK": "Slovakia",
+		"SL": "Sierra Leone",
+		"SM": "San Marino",
+		"SN": "Senegal",
+		"SO": "Somalia",
+		"SR": "Suriname",
+		"SS": "South Sudan",
+		"ST": "São Tomé and Príncipe",
+		"SV": "El Salvador",
+		"SX": "Sint Maarten",
+		"SY": "Syria",
+		"SZ": "Eswatini",
+		"TC": "Turks and Caicos Islands",
+		"TD": "Chad",
+		"TF": "French Southern Territories",
+		"TG": "Togo",
+		"TH": "Thailand",
+		"TJ": "Tajikistan",
+		"TK": "Tokelau",
+		"TL": "Timor-Leste",
+		"TM": "Turkmenistan",
+		"TN": "Tunisia",
+		"TO": "Tonga",
+		"TR": "Turkey",
+		"TT": "Trinidad and Tobago",
+		"TV": "Tuvalu",
+		"TW": "Taiwan",
+		"TZ": "Tanzania",
+		"UA": "Ukraine",
+		"UG": "Uganda",
+		"UM": "U.S. Outlying Islands",
+		"US": "United States",
+		"UY": "Uruguay",
+		"UZ": "Uzbekistan",
+		"VA": "Vatican City",
+		"VC": "Saint Vincent and the Grenadines",
+		"VE": "Venezuela",
+		"VG": "British Virgin Islands",
+		"VI": "U.S. Virgin Islands",
+		"VN": "Vietnam",
+		"VU": "Vanuatu",
+		"WF": "Wallis and Futuna",
+		"WS": "Samoa",
+		"YE": "Yemen",
+		"YT": "Mayotte",
+		"ZA": "South Africa",
+		"ZM": "Zambia",
+		"ZW": "Zimbabwe",
+	}
+	// 日文国家名称
+	countryNamesJA = map[string]string{
+		"AD": "アンドラ",
+		"AE": "アラブ首長国連邦",
+		"AF": "アフガニスタン",
+		"AG": "アンティグア・バーブーダ",
+		"AI": "アンギラ",
+		"AL": "アルバニア",
+		"AM": "アルメニア",
+		"AO": "アンゴラ",
+		"AQ": "南極",
+		"AR": "アルゼンチン",
+		"AS": "米領サモア",
+		"AT": "オーストリア",
+		"AU": "オーストラリア",
+		"AW": "アルバ",
+		"AX": "オーランド諸島",
+		"AZ": "アゼルバイジャン",
+		"BA": "ボスニア・ヘルツェゴビナ",
+		"BB": "バルバドス",
+		"BD": "バングラデシュ",
+		"BE": "ベルギー",
+		"BF": "ブルキナファソ",
+		"BG": "ブルガリア",
+		"BH": "バーレーン",
+		"BI": "ブルンジ",
+		"BJ": "ベナン",
+		"BL": "サン・バルテルミー",
+		"BM": "バミューダ",
+		"BN": "ブルネイ",
+		"BO": "ボリビア",
+		"BQ": "カリブ・オランダ",
+		"BR": "ブラジル",
+		"BS": "バハマ",
+		"BT": "ブータン",
+		"BV": "ブーベ島",
+		"BW": "ボツワナ",
+		"BY": "ベラルーシ",
+		"BZ": "ベリーズ",
+		"CA": "カナダ",
+		"CC": "ココス諸島",
+		"CD": "コンゴ民主共和国",
+		"CF": "中央アフリカ",
+		"CG": "コンゴ共和国",
+		"CH": "スイス",
+		"CI": "コートジボワール",
+		"CK": "クック諸島",
+		"CL": "チリ",
+		"CM": "カメルーン",
+		"CN": "中国",
+		"CO": "コロンビア",
+		"CR": "コスタリカ",
+		"CU": "キューバ",
+		"CV": "カーボベルデ",
+		"CW": "キュラソー",
+		"CX": "クリスマス島",
+		"CY": "キプロス",
+		"CZ": "チェコ",
+		"DE": "ドイツ",
+		"DJ": "ジブチ",
+		"DK": "デンマーク",
+		"DM": "ドミニカ国",
+		"DO": "ドミニカ共和国",
+		"DZ": "アルジェリア",
+		"EC": "エクアドル",
+		"EE": "エストニア",
+		"EG": "エジプト",
+		"EH": "西サハラ",
+		"ER": "エリトリア",
+		"ES": "スペイン",
+		"ET": "エチオピア",
+		"FI": "フィンランド",
+		"FJ": "フィジー",
+		"FK": "フォークランド諸島",
+		"FM": "ミクロネシア",
+		"FO": "フェロー諸島",
+		"FR": "フランス",
+		"GA": "ガボン",
+		"GB": "イギリス",
+		"GD": "グレナダ",
+		"GE": "ジョージア",
+		"GF": "仏領ギアナ",
+		"GG": "ガーンジー",
+		"GH": "ガーナ",
+		"GI": "ジブラルタル",
+		"GL": "グリーンランド",
+		"GM": "ガンビア",
+		"GN": "ギニア",
+		"GP": "グアドループ",
+		"GQ": "赤道ギニア",
+		"GR": "ギリシャ",
+		"GS": "サウスジョージア",
+		"GT": "グアテマラ",
+		"GU": "グアム",
+		"GW": "ギニアビサウ",
+		"GY": "ガイアナ",
+		"HK": "香港",
+		"HM": "ハード島・マクドナルド諸島",
+		"HN": "ホンジュラス",
+		"HR": "クロアチア",
+		"HT": "ハイチ",
+		"HU": "ハンガリー",
+		"ID": "インドネシア",
+		"IE": "アイルランド",
+		"IL": "イスラエル",
+		"IM": "マン島",
+		"IN": "インド",
+		"IO": "英領インド洋地域",
+		"IQ": "イラク",
+		"IR": "イラン",
+		"IS": "アイスランド",
+		"IT": "イタリア",
+		"JE": "ジャージー",
+		"JM": "ジャマイカ",
+		"JO": "ヨルダン",
+		"JP": "日本",
+		"KE": "ケニア",
+		"KG": "キルギス",
+		"KH": "カンボジア",
+		"KI": "キリバス",
+		"KM": "コモロ",
+		"KN": "セントクリストファー・ネイビス",
+		"KP": "北朝鮮",
+		"KR": "韓国",
+		"KW": "クウェート",
+		"KY": "ケイマン諸島",
+		"KZ": "カザフスタン",
+		"LA": "ラオス",
+		"LB": "レバノン",
+		"LC": "セントルシア",
+		"LI": "リヒテンシュタイン",
+		"LK": "スリランカ",
+		"LR": "リベリア",
+		"LS": "レソト",
+		"LT": "リトアニア",
+		"LU": "ルクセンブルク",
+		"LV": "ラトビア",
+		"LY": "リビア",
+		"MA": "モロッコ",
+		"MC": "モナコ",
+		"MD": "モルドバ",
+		"ME": "モンテネグロ",
+		"MF": "サン・マルタン",
+		"MG": "マダガスカル",
+		"MH": "マーシャル諸島",
+		"MK": "北マケドニア",
+		"ML": "マリ",
+		"MM": "ミャンマー",
+		"MN": "モンゴル",
+		"MO": "マカオ",
+		"MP": "北マリアナ諸島",
+		"MQ": "マルティニーク",
+		"MR": "モーリタニア",
+		"MS": "モントセラト",
+		"MT": "マルタ",
+		"MU": "モーリシャス",
+		"MV": "モルディブ",
+		"MW": "マラウイ",
+		"MX": "メキシコ",
+		"MY": "マレーシア",
+		"MZ": "モザンビーク",
+		"NA": "ナミビア",
+		"NC": "ニューカレドニア",
+		"NE": "ニジェール",
+		"NF": "ノーフォーク島",
+		"NG": "ナイジェリア",
+		"NI": "ニカラグア",
+		"NL": "オランダ",
+		"NO": "ノルウェー",
+		"NP": "ネパール",
+		"NR": "ナウル",
+		"NU": "ニウエ",
+		"NZ": "ニュージーランド",
+		"OM": "オマーン",
+		"PA": "パナマ",
+		"PE": "ペルー",
+		"PF": "仏領ポリネシア",
+		"PG": "パプアニューギニア",
+		"PH": "フィリピン",
+		"PK": "パキスタン",
+		"PL": "ポーランド",
+		"PM": "サンピエール・ミクロン",
+		"PN": "ピトケアン諸島",
+		"PR": "プエルトリコ",
+		"PS": "パレスチナ",
+		"PT": "ポルトガル",
+		"PW": "パラオ",
+		"PY": "パラグアイ",
+		"QA": "カタール",
+		"RE": "レユニオン",
+		"RO": "ルーマニア",
+		"RS": "セルビア",
+		"RU": "ロシア",
+		"RW": "ルワンダ",
+		"SA": "サウジアラビア",
+		"SB": "ソロモン諸島",
+		"SC": "セーシェル",
+		"SD": "スーダン",
+		"SE": "スウェーデン",
+		"SG": "シンガポール",
+		"SH": "セントヘレナ",
+		"SI": "スロベニア",
+		"SJ": "スヴァールバル・ヤンマイエン",
+		"SK": "スロバキア",
+		"SL": "シエラレオネ",
+		"SM": "サンマリノ",
+		"SN": "セネガル",
+		"SO": "ソマリア",
+		"SR": "スリナム",
+		"SS": "南スーダン",
+		"ST": "サントメ・プリンシペ",
+		"SV": "エルサルバドル",
+		"SX": "シント・マールテン",
+		"SY": "シリア",
+		"SZ": "エスワティニ",
+		"TC": "タークス・カイコス諸島",
+		"TD": "チャド",
+		"TF": "仏領南方・南極地域",
+		"TG": "トーゴ",
+		"TH": "タイ",
+		"TJ": "タジキスタン",
+		"TK": "トケラウ",
+		"TL": "東ティモール",
+		"TM": "トルクメニスタン",
+		"TN": "チュニジア",
+		"TO": "トンガ",
+		"TR": "トルコ",
+		"TT": "トリニダード・トバゴ",
+		"TV": "ツバル",
+		"TW": "台湾",
+		"TZ": "タンザニア",
+		"UA": "ウクライナ",
+		"UG": "ウガンダ",
+		"UM": "合衆国領有小離島",
+		"US": "アメリカ",
+		"UY": "ウルグアイ",
+		"UZ": "ウズベキスタン",
+		"VA": "バチカン",
+		"VC": "セントビンセント・グレナディーン",
+		"VE": "ベネズエラ",
+		"VG": "英領ヴァージン諸島",
+		"VI": "米領ヴァージン諸島",
+		"VN": "ベトナム",
+		"VU": "バヌアツ",
+		"WF": "ウォリス・フツナ",
+		"WS": "サモア",
+		"YE": "イエメン",
+		"YT": "マヨット",
+		"ZA": "南アフリカ",
+		"ZM": "ザンビア",
+		"ZW": "ジンバブエ",
+	}
+)
+
+// CountryName 按 name-locale 返回国家代码对应的名称
+// country-alias 中的自定义名称优先，未知代码原样返回
+func CountryName(code string) string {
+	code = strings.ToUpper(code)
+	for k, v := range config.GlobalConfig.CountryAlias {
+		if strings.EqualFold(k, code) && v != "" {
+			return v
+		}
+	}
+
+	var names map[string]string
+	switch strings.ToLower(config.GlobalConfig.NameLocale) {
+	case "en":
+		names = countryNamesEN
+	case "ja":
+		names = countryNamesJA
+	default:
+		names = countryAlias
+	}
+	if name := names[code]; name != "" {
+		return name
+	}
+	return code
+}
+
+// ClaimedCountry 从节点名中识别声称的国家代码，依次尝试国旗、中文名、英文名和名称开头的两位代码
+// 识别不到时返回空
+func ClaimedCountry(name string) string {
+	runes := []rune(name)
+	for i := 0; i+1 < len(runes); i++ {
+		if isRegionalIndicator(runes[i]) && isRegionalIndicator(runes[i+1]) {
+			return string(rune('A'+runes[i]-0x1F1E6)) + string(rune('A'+runes[i+1]-0x1F1E6))
+		}
+	}
+
+	if code := matchCountryName(name, countryAlias, false); code != "" {
+		return code
+	}
+	if code := matchCountryName(name, countryNamesEN, true); code != "" {
+		return code
+	}
+	return leadingCountryCode(name)
+}
+
+// ambiguousCodes 常作为解锁、线路标记出现在节点名中的两位代码，不作为声称的国家
+var ambiguousCodes = map[string]bool{
+	"AI": true, // AI 解锁
+	"NF": true, // Netflix
+	"GM": true, // Gemini
+	"GP": true, // GPT
+	"TK": true, // TikTok
+	"YT": true, // YouTube
+}
+
+// leadingCountryCode 识别名称开头形如 "HK 01"、"[US]-LA" 的两位代码，后面必须是分隔符、数字或结尾
+// 名称中间的两位大写字母多是运营商或解锁标记，不识别
+func leadingCountryCode(name string) string {
+	name = strings.TrimLeft(name, " \t[(【「<|-_·")
+	if len(name) < 2 || !isUpperASCII(name[0]) || !isUpperASCII(name[1]) {
+		return ""
+	}
+	if len(name) > 2 && (isUpperASCII(name[2]) || isASCIILetter(name, 2)) {
+		return ""
+	}
+	code := name[:2]
+	if ambiguousCodes[code] || countryAlias[code] == "" {
+		return ""
+	}
+	return code
+}
+
+func isUpperASCII(b byte) bool {
+	return b >= 'A' && b <= 'Z'
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// regionPrefix 去掉地区名前的"中国"，避免"中国香港"被识别为中国
+var regionPrefix = strings.NewReplacer("中国香港", "香港", "中国澳门", "澳门", "中国台湾", "台湾")
+
+// matchCountryName 返回名称中出现的最长国家名对应的代码，避免"南非"被识别成其他国家
+// 长度相同时取最先出现的，如"香港 中国移动"识别为香港
+// wordOnly 为 true 时要求前后不是字母，且忽略过短的英文名
+func matchCountryName(name string, names map[string]string, wordOnly bool) string {
+	lower := strings.ToLower(regionPrefix.Replace(name))
+	var best string
+	bestLen, bestIdx := 0, -1
+	for code, country := range names {
+		c := strings.ToLower(country)
+		if len(c) < bestLen || (wordOnly && len(c) < 4) {
+			continue
+		}
+		idx := indexCountryName(lower, c, wordOnly)
+		if idx < 0 {
+			continue
+		}
+		if len(c) == bestLen && (idx > bestIdx || (idx == bestIdx && code > best)) {
+			continue
+		}
+		best, bestLen, bestIdx = code, len(c), idx
+	}
+	return best
+}
+
+// indexCountryName 返回国家名第一次出现的位置，wordOnly 时跳过前后是字母的位置
+func indexCountryName(s, c string, wordOnly bool) int {
+	for start := 0; start < len(s); {
+		idx := strings.Index(s[start:], c)
+		if idx < 0 {
+			return -1
+		}
+		idx += start
+		if !wordOnly || (!isASCIILetter(s, idx-1) && !isASCIILetter(s, idx+len(c))) {
+			return idx
+		}
+		start = idx + 1
+	}
+	return -1
+}
+
+func isASCIILetter(s string, i int) bool {
+	return i >= 0 && i < len(s) && s[i] >= 'a' && s[i] <= 'z'
+}
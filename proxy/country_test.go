@@ -0,0 +1,73 @@
+package proxies
+
+import (
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+func TestClaimedCountry(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"🇺🇸 美国 01", "US"},
+		{"香港 IPLC 02", "HK"},
+		{"中国香港 01", "HK"},
+		{"香港 中国移动 01", "HK"},
+		{"香港-中国联通中转", "HK"},
+		{"中国台湾 HiNet", "TW"},
+		{"中国 广州移动 01", "CN"},
+		{"南非 01", "ZA"},
+		{"Japan Tokyo 01", "JP"},
+		{"Singapore-01", "SG"},
+		{"HK 01", "HK"},
+		{"[US]-LA", "US"},
+		{"JP01|NF", "JP"},
+		{"Node AI 01", ""},
+		{"Premium |NF|GM|YT-US", ""},
+		{"AI 01", ""},
+		{"GPT 01", ""},
+		{"HKT 01", ""},
+		{"IPLC 01", ""},
+		{"node 01", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClaimedCountry(tt.name); got != tt.want {
+				t.Errorf("ClaimedCountry(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountryName(t *testing.T) {
+	locale, alias := config.GlobalConfig.NameLocale, config.GlobalConfig.CountryAlias
+	defer func() {
+		config.GlobalConfig.NameLocale = locale
+		config.GlobalConfig.CountryAlias = alias
+	}()
+
+	tests := []struct {
+		locale string
+		alias  map[string]string
+		code   string
+		want   string
+	}{
+		{"", nil, "hk", "香港"},
+		{"en", nil, "US", "United States"},
+		{"ja", nil, "JP", "日本"},
+		{"", map[string]string{"tw": "台湾省"}, "TW", "台湾省"},
+		{"en", map[string]string{"HK": ""}, "HK", "Hong Kong"},
+		{"", nil, "XX", "XX"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale+"/"+tt.code, func(t *testing.T) {
+			config.GlobalConfig.NameLocale = tt.locale
+			config.GlobalConfig.CountryAlias = tt.alias
+			if got := CountryName(tt.code); got != tt.want {
+				t.Errorf("CountryName(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
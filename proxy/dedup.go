@@ -1,36 +1,155 @@
 package proxies
 
 import (
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
 )
 
-func DeduplicateProxies(proxies []map[string]any) []map[string]any {
-	seenKeys := make(map[string]bool)
-	result := make([]map[string]any, 0, len(proxies))
+// fingerprintFields 各协议中影响连接的字段，节点名称、来源等字段不参与去重
+var fingerprintFields = map[string][]string{
+	"ss":        {"server", "port", "cipher", "password", "plugin", "plugin-opts", "udp-over-tcp", "udp-over-tcp-version"},
+	"ssr":       {"server", "port", "cipher", "password", "obfs", "obfs-param", "protocol", "protocol-param"},
+	"vmess":     {"server", "port", "uuid", "alterId", "cipher", "network", "tls", "servername", "ws-opts", "h2-opts", "http-opts", "grpc-opts", "reality-opts"},
+	"vless":     {"server", "port", "uuid", "flow", "encryption", "network", "tls", "servername", "ws-opts", "h2-opts", "http-opts", "grpc-opts", "xhttp-opts", "reality-opts"},
+	"trojan":    {"server", "port", "password", "network", "sni", "ws-opts", "grpc-opts", "reality-opts", "ss-opts"},
+	"hysteria":  {"server", "port", "ports", "auth", "auth-str", "obfs", "protocol", "sni", "alpn"},
+	"hysteria2": {"server", "port", "ports", "password", "obfs", "obfs-password", "sni", "alpn"},
+	"tuic":      {"server", "port", "uuid", "password", "token", "sni", "congestion-controller", "udp-relay-mode", "alpn"},
+	"wireguard": {"server", "port", "private-key", "public-key", "pre-shared-key", "ip", "ipv6", "reserved", "peers", "amnezia-wg-option"},
+	"socks5":    {"server", "port", "username", "password", "tls"},
+	"http":      {"server", "port", "username", "password", "tls", "sni", "headers"},
+	"snell":     {"server", "port", "psk", "version", "obfs-opts"},
+	"anytls":    {"server", "port", "password", "sni"},
+	"mieru":     {"server", "port", "port-range", "transport", "username", "password", "multiplexing"},
+	"ssh":       {"server", "port", "username", "password", "private-key", "private-key-passphrase", "host-key"},
+}
 
-	for _, proxy := range proxies {
-		server, _ := proxy["server"].(string)
-		if server == "" {
+// ignoredFields 未知协议计算指纹时忽略的字段
+var ignoredFields = map[string]bool{
+	"name":             true,
+	"sub_url":          true,
+	"sub_tag":          true,
+	"udp":              true,
+	"tfo":              true,
+	"mptcp":            true,
+	"skip-cert-verify": true,
+}
+
+// DedupSource 单个订阅被合并掉的重复节点数
+type DedupSource struct {
+	URL    string `json:"url"`
+	Merged int    `json:"merged"`
+}
+
+// DedupReport 去重统计
+type DedupReport struct {
+	Total   int           `json:"total"`
+	Kept    int           `json:"kept"`
+	Merged  int           `json:"merged"`
+	Sources []DedupSource `json:"sources"`
+}
+
+// Fingerprint 按协议计算节点指纹，连接参数完全一致的节点指纹相同
+func Fingerprint(proxy map[string]any) string {
+	t, _ := proxy["type"].(string)
+	t = strings.ToLower(t)
+	if t == "hy2" {
+		t = "hysteria2"
+	}
+
+	fields, ok := fingerprintFields[t]
+	if !ok {
+		for k := range proxy {
+			if !ignoredFields[k] && k != "type" {
+				fields = append(fields, k)
+			}
+		}
+		sort.Strings(fields)
+	}
+
+	var b strings.Builder
+	b.WriteString(t)
+	for _, field := range fields {
+		v, ok := proxy[field]
+		if !ok || v == nil {
 			continue
 		}
-		servername, _ := proxy["servername"].(string)
+		b.WriteString("|")
+		b.WriteString(field)
+		b.WriteString("=")
+		b.WriteString(canonicalValue(field, v))
+	}
+	return b.String()
+}
 
-		password, _ := proxy["password"].(string)
-		if password == "" {
-			password, _ = proxy["uuid"].(string)
+// canonicalValue 将字段值转换为稳定的字符串，嵌套结构按 key 排序序列化
+func canonicalValue(field string, v any) string {
+	switch val := v.(type) {
+	case string:
+		if field == "server" || field == "servername" || field == "sni" {
+			return strings.ToLower(val)
 		}
+		return val
+	case map[string]any, []any:
+		// encoding/json 会对 map 的 key 排序
+		if data, err := json.Marshal(val); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(v)
+}
 
-		sni, _ := proxy["sni"].(string)
+// DeduplicateProxies 按协议指纹去重，保留先出现的节点
+func DeduplicateProxies(proxies []map[string]any) ([]map[string]any, DedupReport) {
+	seenKeys := make(map[string]bool, len(proxies))
+	result := make([]map[string]any, 0, len(proxies))
+	merged := make(map[string]int)
 
-		network, _ := proxy["network"].(string)
+	for _, proxy := range proxies {
+		key := Fingerprint(proxy)
+		if seenKeys[key] {
+			subUrl, _ := proxy["sub_url"].(string)
+			merged[subUrl]++
+			continue
+		}
+		seenKeys[key] = true
+		result = append(result, proxy)
+	}
 
-		key := fmt.Sprintf("%s:%v:%s:%s:%s:%s", server, proxy["port"], servername, password, sni, network)
+	report := DedupReport{
+		Total: len(proxies),
+		Kept:  len(result),
+	}
+	for url, n := range merged {
+		report.Merged += n
+		report.Sources = append(report.Sources, DedupSource{URL: url, Merged: n})
+	}
+	sort.Slice(report.Sources, func(i, j int) bool {
+		if report.Sources[i].Merged != report.Sources[j].Merged {
+			return report.Sources[i].Merged > report.Sources[j].Merged
+		}
+		return report.Sources[i].URL < report.Sources[j].URL
+	})
 
-		if !seenKeys[key] {
-			seenKeys[key] = true
-			result = append(result, proxy)
+	logDedupReport(report)
+	return result, report
+}
+
+// logDedupReport 打印各来源被合并的重复节点数
+func logDedupReport(report DedupReport) {
+	if report.Merged == 0 {
+		return
+	}
+	slog.Info(fmt.Sprintf("去重合并重复节点: %d", report.Merged), "来源数", len(report.Sources))
+	for _, source := range report.Sources {
+		url := source.URL
+		if url == "" {
+			// keep-success-proxies 保留的节点没有来源
+			url = "上次可用节点"
 		}
+		slog.Info("重复节点来源", "url", url, "合并数量", source.Merged)
 	}
-
-	return result
 }
@@ -0,0 +1,56 @@
+package proxies
+
+import (
+	"testing"
+)
+
+func TestDeduplicateProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []map[string]any
+		want    int
+	}{
+		{
+			name: "ss different cipher",
+			proxies: []map[string]any{
+				{"name": "a", "type": "ss", "server": "1.1.1.1", "port": 443, "cipher": "aes-128-gcm", "password": "p"},
+				{"name": "b", "type": "ss", "server": "1.1.1.1", "port": 443, "cipher": "chacha20-ietf-poly1305", "password": "p"},
+			},
+			want: 2,
+		},
+		{
+			name: "vmess different ws path",
+			proxies: []map[string]any{
+				{"name": "a", "type": "vmess", "server": "a.com", "port": 443, "uuid": "u", "network": "ws", "ws-opts": map[string]any{"path": "/a"}},
+				{"name": "b", "type": "vmess", "server": "a.com", "port": 443, "uuid": "u", "network": "ws", "ws-opts": map[string]any{"path": "/b"}},
+			},
+			want: 2,
+		},
+		{
+			name: "same node from different subs",
+			proxies: []map[string]any{
+				{"name": "a", "type": "trojan", "server": "A.com", "port": 443, "password": "p", "sub_url": "x"},
+				{"name": "b", "type": "trojan", "server": "a.com", "port": "443", "password": "p", "sub_url": "y", "udp": true},
+			},
+			want: 1,
+		},
+		{
+			name: "node without server",
+			proxies: []map[string]any{
+				{"name": "a", "type": "wireguard", "private-key": "k", "peers": []any{map[string]any{"server": "1.1.1.1", "port": 51820}}},
+			},
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, report := DeduplicateProxies(tt.proxies)
+			if len(got) != tt.want {
+				t.Errorf("DeduplicateProxies() kept %d, want %d", len(got), tt.want)
+			}
+			if report.Merged != len(tt.proxies)-tt.want {
+				t.Errorf("DeduplicateProxies() merged %d, want %d", report.Merged, len(tt.proxies)-tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package proxies
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ip-api.com 免费接口限制每分钟 45 次，留出余量给出口位置查询
+const entryQueryLimit = 30
+
+var (
+	entryLock    sync.Mutex
+	entryWindow  time.Time
+	entryQueries int
+	entryMisses  = make(map[string]bool) // 本轮在线查询失败的入口IP，不再重复请求
+)
+
+// directClient 不经过节点直接查询，用于查询入口IP位置
+var directClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	},
+}
+
+// GetEntryGeo 解析节点配置的服务器地址并查询入口IP位置
+// 优先使用缓存和本地数据库，都没有时直接请求 ip-api.com，不经过节点
+// 在线查询每分钟最多 entryQueryLimit 次，超出或本轮已查询失败的IP只返回IP不返回位置
+func GetEntryGeo(proxy map[string]any) GeoInfo {
+	host, _ := proxy["server"].(string)
+	if host == "" {
+		return GeoInfo{}
+	}
+
+	ip := host
+	if net.ParseIP(host) == nil {
+		ips, err := lookupHost(host)
+		if err != nil {
+			slog.Debug(fmt.Sprintf("解析入口地址失败: %s: %v", host, err))
+			return GeoInfo{}
+		}
+		ip = pickIP(ips)
+	}
+
+	if geo, ok := cachedGeo(ip); ok {
+		return geo
+	}
+	var geo GeoInfo
+	if mmdbLoaded() {
+		geo = LookupMMDB(ip)
+	}
+	if geo.Country == "" && allowEntryQuery(ip) {
+		geo = queryIPAPICom(directClient, ip)
+		if geo.Country == "" {
+			entryLock.Lock()
+			entryMisses[ip] = true
+			entryLock.Unlock()
+		}
+	}
+	if geo.Country == "" {
+		return GeoInfo{IP: ip}
+	}
+	geo.IP = ip
+	cacheGeo(ip, geo)
+	return geo
+}
+
+// ResetEntryGeo 每轮检测前调用，清空上一轮查询失败的记录
+func ResetEntryGeo() {
+	entryLock.Lock()
+	defer entryLock.Unlock()
+	entryMisses = make(map[string]bool)
+}
+
+// allowEntryQuery 判断是否可以在线查询入口IP，超出频率限制时不等待，直接跳过
+func allowEntryQuery(ip string) bool {
+	entryLock.Lock()
+	defer entryLock.Unlock()
+	if entryMisses[ip] {
+		return false
+	}
+	if time.Since(entryWindow) >= time.Minute {
+		entryWindow, entryQueries = time.Now(), 0
+	}
+	if entryQueries >= entryQueryLimit {
+		return false
+	}
+	entryQueries++
+	return true
+}
+
+// pickIP 优先返回 IPv4 地址
+func pickIP(ips []string) string {
+	for _, ip := range ips {
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+			return ip
+		}
+	}
+	return ips[0]
+}
@@ -0,0 +1,32 @@
+package proxies
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAllowEntryQuery(t *testing.T) {
+	ResetEntryGeo()
+	entryWindow, entryQueries = time.Now(), 0
+	defer ResetEntryGeo()
+
+	for i := range entryQueryLimit {
+		if !allowEntryQuery(fmt.Sprintf("10.0.0.%d", i)) {
+			t.Fatalf("query %d rejected before limit", i)
+		}
+	}
+	if allowEntryQuery("10.0.1.1") {
+		t.Error("query allowed over limit")
+	}
+
+	// 新的一分钟重新计数，本轮失败过的IP仍然跳过
+	entryWindow = time.Now().Add(-time.Minute)
+	entryMisses["10.0.0.1"] = true
+	if allowEntryQuery("10.0.0.1") {
+		t.Error("failed ip queried again")
+	}
+	if !allowEntryQuery("10.0.1.1") {
+		t.Error("query rejected in new window")
+	}
+}
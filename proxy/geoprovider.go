@@ -0,0 +1,220 @@
+package proxies
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/metacubex/mihomo/common/convert"
+)
+
+// geoFunc 通过节点查询出口IP位置，失败时返回空的 GeoInfo
+type geoFunc func(httpClient *http.Client) GeoInfo
+
+var defaultGeoProviders = []string{"mmdb", "ipapi", "ip-api"}
+
+// builtinGeoProviders 内置的位置查询接口
+var builtinGeoProviders = map[string]geoFunc{
+	"mmdb":    getMMDBGeo,
+	"ipapi":   getIPAPIGeo,
+	"ip-api":  getIPAPIComGeo,
+	"edgeone": countryOnly(GetEdgeOneProxy),
+	"cf":      countryOnly(GetCFProxy),
+	"iplark":  countryOnly(GetIPLark),
+	"me":      countryOnly(GetMe),
+}
+
+// countryOnly 适配只返回国家和IP的接口
+func countryOnly(f func(httpClient *http.Client) (loc string, ip string)) geoFunc {
+	return func(httpClient *http.Client) GeoInfo {
+		loc, ip := f(httpClient)
+		return GeoInfo{IP: ip, Country: loc}
+	}
+}
+
+// getMMDBGeo 通过IP回显接口获取出口IP，再用本地数据库查询位置，未加载数据库时直接跳过
+func getMMDBGeo(httpClient *http.Client) GeoInfo {
+	if !mmdbLoaded() {
+		return GeoInfo{}
+	}
+	return lookupMMDBGeo(GetEchoIP(httpClient))
+}
+
+// mmdbGeoFor 已经拿到出口IP时直接查询本地数据库，不再请求回显接口
+func mmdbGeoFor(ip string) geoFunc {
+	return func(*http.Client) GeoInfo {
+		if !mmdbLoaded() {
+			return GeoInfo{}
+		}
+		return lookupMMDBGeo(ip)
+	}
+}
+
+func lookupMMDBGeo(ip string) GeoInfo {
+	if ip == "" {
+		return GeoInfo{}
+	}
+	return LookupMMDB(ip)
+}
+
+type namedGeoFunc struct {
+	name string
+	fn   geoFunc
+}
+
+// geoProviders 按配置顺序返回查询接口，未知名称会被忽略
+// echoIP 为已经通过回显接口拿到的出口IP，不为空时 mmdb 直接使用
+func geoProviders(echoIP string) []namedGeoFunc {
+	names := config.GlobalConfig.GeoProviders
+	if len(names) == 0 {
+		names = defaultGeoProviders
+	}
+
+	custom := make(map[string]config.GeoProvider)
+	for _, p := range config.GlobalConfig.GeoCustom {
+		custom[p.Name] = p
+	}
+
+	providers := make([]namedGeoFunc, 0, len(names))
+	for _, name := range names {
+		if p, ok := custom[name]; ok {
+			providers = append(providers, namedGeoFunc{name: name, fn: customGeo(p)})
+			continue
+		}
+		if strings.EqualFold(name, "mmdb") && echoIP != "" {
+			providers = append(providers, namedGeoFunc{name: name, fn: mmdbGeoFor(echoIP)})
+			continue
+		}
+		if fn, ok := builtinGeoProviders[strings.ToLower(name)]; ok {
+			providers = append(providers, namedGeoFunc{name: name, fn: fn})
+			continue
+		}
+		slog.Debug(fmt.Sprintf("未知的位置查询接口: %s", name))
+	}
+	return providers
+}
+
+func geoOK(geo GeoInfo) bool {
+	return geo.Country != "" && geo.IP != ""
+}
+
+// chainGeo 依次尝试各个接口
+func chainGeo(httpClient *http.Client, providers []namedGeoFunc) GeoInfo {
+	for _, p := range providers {
+		if geo := p.fn(httpClient); geoOK(geo) {
+			return geo
+		}
+	}
+	return GeoInfo{}
+}
+
+// raceGeo 同时请求所有接口，返回最先成功的结果
+func raceGeo(httpClient *http.Client, providers []namedGeoFunc) GeoInfo {
+	ch := make(chan GeoInfo, len(providers))
+	for _, p := range providers {
+		go func(fn geoFunc) {
+			ch <- fn(httpClient)
+		}(p.fn)
+	}
+	for range providers {
+		if geo := <-ch; geoOK(geo) {
+			return geo
+		}
+	}
+	return GeoInfo{}
+}
+
+// customGeo 按配置的字段路径解析 json 接口
+func customGeo(p config.GeoProvider) geoFunc {
+	return func(httpClient *http.Client) GeoInfo {
+		ua := p.UserAgent
+		if ua == "" {
+			ua = convert.RandUserAgent()
+		}
+		req, cancel, err := newGeoRequest(http.MethodGet, p.Url, ua)
+		if err != nil {
+			slog.Debug(fmt.Sprintf("创建请求失败: %s", err))
+			return GeoInfo{}
+		}
+		defer cancel()
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			slog.Debug(fmt.Sprintf("%s获取节点位置失败: %s", p.Name, err))
+			return GeoInfo{}
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			slog.Debug(fmt.Sprintf("%s返回非200状态码: %v", p.Name, resp.StatusCode))
+			return GeoInfo{}
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			slog.Debug(fmt.Sprintf("%s读取节点位置失败: %s", p.Name, err))
+			return GeoInfo{}
+		}
+
+		var data any
+		if err := json.Unmarshal(body, &data); err != nil {
+			slog.Debug(fmt.Sprintf("解析%s JSON 失败: %v", p.Name, err))
+			return GeoInfo{}
+		}
+
+		geo := GeoInfo{
+			IP:      jsonPath(data, p.IPPath),
+			Country: strings.ToUpper(jsonPath(data, p.CountryPath)),
+			Region:  jsonPath(data, p.RegionPath),
+			City:    jsonPath(data, p.CityPath),
+			ISP:     jsonPath(data, p.ISPPath),
+		}
+		geo.Lat, _ = strconv.ParseFloat(jsonPath(data, p.LatPath), 64)
+		geo.Lon, _ = strconv.ParseFloat(jsonPath(data, p.LonPath), 64)
+		if asn := jsonPath(data, p.ASNPath); asn != "" {
+			// 兼容 13335、AS13335、"AS13335 Cloudflare" 等写法
+			asn, _, _ = strings.Cut(asn, " ")
+			if !strings.HasPrefix(strings.ToUpper(asn), "AS") {
+				asn = "AS" + asn
+			}
+			geo.ASN = strings.ToUpper(asn)
+		}
+		return geo
+	}
+}
+
+// jsonPath 按 . 分隔的路径取值，数组使用下标，取不到时返回空字符串
+func jsonPath(data any, path string) string {
+	if path == "" {
+		return ""
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch v := data.(type) {
+		case map[string]any:
+			data = v[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return ""
+			}
+			data = v[i]
+		default:
+			return ""
+		}
+	}
+
+	switch v := data.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
@@ -45,86 +45,69 @@ func GetProxies() ([]map[string]any, error) {
 		done <- struct{}{}
 	}()
 
+	// 连续异常的订阅会被隔离，本轮跳过
+	quarantined := prepareSubHealth(subUrls)
+	if len(quarantined) > 0 {
+		slog.Warn(fmt.Sprintf("跳过已隔离的订阅数量: %d", len(quarantined)))
+	}
+
 	// 启动工作协程
 	for _, subUrl := range subUrls {
+		if quarantined[subUrl] {
+			slog.Debug("跳过已隔离的订阅", "url", subUrl)
+			continue
+		}
 		wg.Add(1)
 		concurrentLimit <- struct{}{} // 获取令牌
 
-		go func(url string) {
+		go func(subUrl string) {
 			defer wg.Done()
 			defer func() { <-concurrentLimit }() // 释放令牌
 
-			data, err := GetDateFromSubs(url)
-			if err != nil {
-				slog.Error(fmt.Sprintf("获取订阅链接错误跳过: %v", err))
-				return
-			}
-
+			url := utils.WarpUrl(subUrl)
 			var tag string
 			if d, err := u.Parse(url); err == nil {
 				tag = d.Fragment
 			}
 
-			var con map[string]any
-			err = yaml.Unmarshal(data, &con)
+			data, err := GetDateFromSubs(url)
 			if err != nil {
-				proxyList, err := convert.ConvertsV2Ray(data)
-				if err != nil {
-					slog.Error(fmt.Sprintf("解析proxy错误: %v", err), "url", url)
-					return
-				}
-				slog.Debug(fmt.Sprintf("获取订阅链接: %s，有效节点数量: %d", url, len(proxyList)))
-				for _, proxy := range proxyList {
-					// 只测试指定协议
-					if t, ok := proxy["type"].(string); ok {
-						if len(config.GlobalConfig.NodeType) > 0 && !lo.Contains(config.GlobalConfig.NodeType, t) {
-							continue
-						}
-					}
-
-					// 为每个节点添加订阅链接来源信息和备注
-					proxy["sub_url"] = url
-					proxy["sub_tag"] = tag
-					proxyChan <- proxy
-				}
-				return
-			}
-
-			proxyInterface, ok := con["proxies"]
-			if !ok || proxyInterface == nil {
-				slog.Error(fmt.Sprintf("订阅链接没有proxies: %s", url))
+				slog.Error(fmt.Sprintf("获取订阅链接错误跳过: %v", err))
+				recordSubFetch(subUrl, tag, false, 0)
 				return
 			}
 
-			proxyList, ok := proxyInterface.([]any)
-			if !ok {
+			proxyList, err := parseSubData(data, url, 0, &providerResolver{})
+			if err != nil {
+				slog.Error(err.Error(), "url", url)
+				recordSubFetch(subUrl, tag, false, 0)
 				return
 			}
+			recordSubFetch(subUrl, tag, true, len(proxyList))
 			slog.Debug(fmt.Sprintf("获取订阅链接: %s，有效节点数量: %d", url, len(proxyList)))
-			for _, proxy := range proxyList {
-				if proxyMap, ok := proxy.(map[string]any); ok {
-					if t, ok := proxyMap["type"].(string); ok {
-						// 只测试指定协议
-						if len(config.GlobalConfig.NodeType) > 0 && !lo.Contains(config.GlobalConfig.NodeType, t) {
-							continue
-						}
-						// 虽然支持mihomo支持下划线，但是这里为了规范，还是改成横杠
-						// todo: 不知道后边还有没有这类问题
-						switch t {
-						case "hysteria2", "hy2":
-							if _, ok := proxyMap["obfs_password"]; ok {
-								proxyMap["obfs-password"] = proxyMap["obfs_password"]
-								delete(proxyMap, "obfs_password")
-							}
+			for _, proxyMap := range proxyList {
+				if t, ok := proxyMap["type"].(string); ok {
+					// 只测试指定协议
+					if len(config.GlobalConfig.NodeType) > 0 && !lo.Contains(config.GlobalConfig.NodeType, t) {
+						continue
+					}
+					// 虽然支持mihomo支持下划线，但是这里为了规范，还是改成横杠
+					// todo: 不知道后边还有没有这类问题
+					switch t {
+					case "hysteria2", "hy2":
+						if _, ok := proxyMap["obfs_password"]; ok {
+							proxyMap["obfs-password"] = proxyMap["obfs_password"]
+							delete(proxyMap, "obfs_password")
 						}
 					}
-					// 为每个节点添加订阅链接来源信息和备注
-					proxyMap["sub_url"] = url
-					proxyMap["sub_tag"] = tag
-					proxyChan <- proxyMap
 				}
+				// 为每个节点添加订阅链接来源信息和备注
+				// sub_url 使用配置中的原始地址，保证带时间占位符的订阅统计口径一致
+				proxyMap["sub_url"] = subUrl
+				proxyMap["sub_tag"] = tag
+				proxyChan <- proxyMap
 			}
-		}(utils.WarpUrl(subUrl))
+		}(subUrl)
 	}
 
 	// 等待所有工作协程完成
@@ -0,0 +1,182 @@
+package proxies
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
+)
+
+const subHealthFile = "sub-health.json"
+
+// SubHealth 单个订阅的历史健康状况
+type SubHealth struct {
+	URL            string    `json:"url"`
+	Tag            string    `json:"tag"`
+	LastRun        time.Time `json:"last_run"`
+	FetchOK        bool      `json:"fetch_ok"`
+	FetchFailures  int       `json:"fetch_failures"`
+	NodeCount      int       `json:"node_count"`
+	Tested         int       `json:"tested"`
+	Success        int       `json:"success"`
+	PassRate       float64   `json:"pass_rate"`
+	ConsecutiveBad int       `json:"consecutive_bad"`
+	Quarantined    bool      `json:"quarantined"`
+	QuarantinedAt  time.Time `json:"quarantined_at"`
+	SkippedRuns    int       `json:"skipped_runs"`
+
+	// 本轮是否拉取过，不持久化
+	fetched bool
+}
+
+// SubStats 本轮检测中单个订阅的节点统计
+type SubStats struct {
+	Tested  int
+	Success int
+}
+
+var (
+	subHealth     map[string]*SubHealth
+	subHealthLock sync.Mutex
+)
+
+// loadSubHealth 首次使用时从状态目录加载，调用方需持有锁
+func loadSubHealth() {
+	if subHealth != nil {
+		return
+	}
+	subHealth = make(map[string]*SubHealth)
+	if err := utils.LoadState(subHealthFile, &subHealth); err != nil {
+		slog.Warn(fmt.Sprintf("加载订阅健康记录失败: %v", err))
+	}
+}
+
+// prepareSubHealth 开始新一轮时调用，清理已移除的订阅，返回需要跳过的隔离订阅
+func prepareSubHealth(urls []string) map[string]bool {
+	subHealthLock.Lock()
+	defer subHealthLock.Unlock()
+	loadSubHealth()
+
+	current := make(map[string]bool, len(urls))
+	for _, url := range urls {
+		current[url] = true
+	}
+	for url, h := range subHealth {
+		if !current[url] {
+			delete(subHealth, url)
+			continue
+		}
+		h.fetched = false
+	}
+
+	skip := make(map[string]bool)
+	if config.GlobalConfig.SubQuarantineRuns <= 0 {
+		return skip
+	}
+	for _, url := range urls {
+		h, ok := subHealth[url]
+		if !ok || !h.Quarantined {
+			continue
+		}
+		// 隔离期间每隔 sub-quarantine-probe-runs 轮重新探测一次
+		if config.GlobalConfig.SubProbeRuns > 0 && h.SkippedRuns >= config.GlobalConfig.SubProbeRuns {
+			h.SkippedRuns = 0
+			slog.Info("重新探测已隔离的订阅", "url", url)
+			continue
+		}
+		h.SkippedRuns++
+		skip[url] = true
+	}
+	return skip
+}
+
+// recordSubFetch 记录订阅拉取结果
+func recordSubFetch(url, tag string, ok bool, nodeCount int) {
+	subHealthLock.Lock()
+	defer subHealthLock.Unlock()
+	loadSubHealth()
+
+	h, exists := subHealth[url]
+	if !exists {
+		h = &SubHealth{URL: url}
+		subHealth[url] = h
+	}
+	h.Tag = tag
+	h.fetched = true
+	h.FetchOK = ok
+	h.NodeCount = nodeCount
+	h.Tested = 0
+	h.Success = 0
+	h.PassRate = 0
+	if !ok {
+		h.FetchFailures++
+	}
+}
+
+// FinishSubHealth 根据本轮检测结果更新订阅健康状况并持久化
+// 拉取失败、没有节点或测试过的节点全部失败记为一次异常，连续异常达到阈值后隔离
+func FinishSubHealth(stats map[string]SubStats) {
+	subHealthLock.Lock()
+	defer subHealthLock.Unlock()
+	loadSubHealth()
+
+	now := time.Now()
+	for url, h := range subHealth {
+		if !h.fetched {
+			continue
+		}
+		h.LastRun = now
+		s := stats[url]
+		h.Tested = s.Tested
+		h.Success = s.Success
+		if s.Tested > 0 {
+			h.PassRate = float64(s.Success) / float64(s.Tested)
+		}
+
+		switch {
+		case !h.FetchOK || h.NodeCount == 0 || (s.Tested > 0 && s.Success == 0):
+			h.ConsecutiveBad++
+		case s.Tested == 0:
+			// 因 success-limit 或强制关闭没有测到的订阅，不做判断
+			continue
+		default:
+			if h.Quarantined {
+				slog.Info("订阅恢复正常，解除隔离", "url", url)
+			}
+			h.ConsecutiveBad = 0
+			h.Quarantined = false
+			h.SkippedRuns = 0
+			continue
+		}
+
+		threshold := config.GlobalConfig.SubQuarantineRuns
+		if threshold > 0 && !h.Quarantined && h.ConsecutiveBad >= threshold {
+			h.Quarantined = true
+			h.QuarantinedAt = now
+			h.SkippedRuns = 0
+			slog.Warn(fmt.Sprintf("订阅连续%d次异常，已隔离: %s", h.ConsecutiveBad, url))
+		}
+	}
+
+	if err := utils.SaveState(subHealthFile, subHealth); err != nil {
+		slog.Warn(fmt.Sprintf("保存订阅健康记录失败: %v", err))
+	}
+}
+
+// SubHealthList 返回所有订阅的健康状况，按地址排序
+func SubHealthList() []SubHealth {
+	subHealthLock.Lock()
+	defer subHealthLock.Unlock()
+	loadSubHealth()
+
+	list := make([]SubHealth, 0, len(subHealth))
+	for _, h := range subHealth {
+		list = append(list, *h)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].URL < list[j].URL })
+	return list
+}
@@ -39,110 +39,189 @@ func newGeoRequest(method, url, ua string) (*http.Request, context.CancelFunc, e
 	return req, cancel, nil
 }
 
+// GeoInfo 出口IP的位置信息，不同接口提供的字段不同，缺失的字段为空
+type GeoInfo struct {
+	IP      string  `json:"ip"`
+	Country string  `json:"country"`          // ISO 3166-1 二位国家代码
+	Region  string  `json:"region,omitempty"` // 省/州
+	City    string  `json:"city,omitempty"`
+	Lat     float64 `json:"lat,omitempty"`
+	Lon     float64 `json:"lon,omitempty"`
+	ASN     string  `json:"asn,omitempty"` // 形如 AS13335
+	ISP     string  `json:"isp,omitempty"`
+}
+
 func GetProxyCountry(httpClient *http.Client) (loc string, ip string) {
+	geo := GetProxyGeo(httpClient)
+	return geo.Country, geo.IP
+}
+
+// GetProxyGeo 通过节点查询出口IP及位置信息，接口顺序和并发方式见 geo-providers、geo-race
+func GetProxyGeo(httpClient *http.Client) GeoInfo {
+	// 开启缓存时先拿出口IP，同一IP的位置只查一次；未命中时 mmdb 复用这个IP，不再请求回显接口
+	var echoIP string
+	if ipCacheEnabled() {
+		echoIP = GetEchoIP(httpClient)
+		if geo, ok := cachedGeo(echoIP); ok {
+			return geo
+		}
+	}
+
+	providers := geoProviders(echoIP)
 	for i := 0; i < config.GlobalConfig.SubUrlsReTry; i++ {
-		// 优先使用更稳定的 ipapi.co
-		loc, ip = GetIPAPI(httpClient)
-		if loc != "" && ip != "" {
-			return
+		var geo GeoInfo
+		if config.GlobalConfig.GeoRace {
+			geo = raceGeo(httpClient, providers)
+		} else {
+			geo = chainGeo(httpClient, providers)
 		}
-		// 次选 ip-api.com，速率宽松
-		loc, ip = GetIPAPICom(httpClient)
-		if loc != "" && ip != "" {
-			return
+		if geoOK(geo) {
+			cacheGeo(geo.IP, geo)
+			// 双栈节点回显接口和位置接口拿到的IP可能不同，两个都记下
+			if echoIP != "" && echoIP != geo.IP {
+				cacheGeo(echoIP, geo)
+			}
+			return geo
 		}
 	}
-	return
+	return GeoInfo{}
 }
 
 func GetIPAPICom(httpClient *http.Client) (loc string, ip string) {
-	type GeoIPData struct {
-		Query       string `json:"query"`
-		CountryCode string `json:"countryCode"`
-		Status      string `json:"status"`
-		Message     string `json:"message"`
-	}
+	geo := getIPAPIComGeo(httpClient)
+	return geo.Country, geo.IP
+}
 
-	req, cancel, err := newGeoRequest(http.MethodGet, "http://ip-api.com/json/?fields=status,message,countryCode,query", convert.RandUserAgent())
+func getIPAPIComGeo(httpClient *http.Client) GeoInfo {
+	return queryIPAPICom(httpClient, "")
+}
+
+// queryIPAPICom 查询指定IP的位置，ip 为空时查询请求方自身的出口IP
+func queryIPAPICom(httpClient *http.Client, ip string) GeoInfo {
+	type GeoIPData struct {
+		Query       string  `json:"query"`
+		CountryCode string  `json:"countryCode"`
+		RegionName  string  `json:"regionName"`
+		City        string  `json:"city"`
+		Lat         float64 `json:"lat"`
+		Lon         float64 `json:"lon"`
+		AS          string  `json:"as"`
+		ISP         string  `json:"isp"`
+		Status      string  `json:"status"`
+		Message     string  `json:"message"`
+	}
+
+	req, cancel, err := newGeoRequest(http.MethodGet, "http://ip-api.com/json/"+ip+"?fields=status,message,countryCode,regionName,city,lat,lon,isp,as,query", convert.RandUserAgent())
 	if err != nil {
 		slog.Debug(fmt.Sprintf("创建请求失败: %s", err))
-		return
+		return GeoInfo{}
 	}
 	defer cancel()
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("ip-api获取节点位置失败: %s", err))
-		return
+		return GeoInfo{}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		slog.Debug(fmt.Sprintf("ip-api返回非200状态码: %v", resp.StatusCode))
-		return
+		return GeoInfo{}
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("ip-api读取节点位置失败: %s", err))
-		return
+		return GeoInfo{}
 	}
 
 	var geo GeoIPData
 	err = json.Unmarshal(body, &geo)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("解析ip-api JSON 失败: %v", err))
-		return
+		return GeoInfo{}
 	}
 
 	if geo.Status != "success" {
 		slog.Debug(fmt.Sprintf("ip-api返回状态非success: %s", geo.Message))
-		return
+		return GeoInfo{}
 	}
 
-	return geo.CountryCode, geo.Query
+	// as 字段形如 "AS13335 Cloudflare, Inc."
+	asn, _, _ := strings.Cut(geo.AS, " ")
+	return GeoInfo{
+		IP:      geo.Query,
+		Country: geo.CountryCode,
+		Region:  geo.RegionName,
+		City:    geo.City,
+		Lat:     geo.Lat,
+		Lon:     geo.Lon,
+		ASN:     asn,
+		ISP:     geo.ISP,
+	}
 }
 
 func GetIPAPI(httpClient *http.Client) (loc string, ip string) {
+	geo := getIPAPIGeo(httpClient)
+	return geo.Country, geo.IP
+}
+
+func getIPAPIGeo(httpClient *http.Client) GeoInfo {
 	type GeoIPData struct {
-		IP      string `json:"ip"`
-		Country string `json:"country_code"`
+		IP      string  `json:"ip"`
+		Country string  `json:"country_code"`
+		Region  string  `json:"region"`
+		City    string  `json:"city"`
+		Lat     float64 `json:"latitude"`
+		Lon     float64 `json:"longitude"`
+		ASN     string  `json:"asn"`
+		Org     string  `json:"org"`
 	}
 
 	// ipapi.co 免费接口，限制较宽松
 	req, cancel, err := newGeoRequest(http.MethodGet, "https://ipapi.co/json", convert.RandUserAgent())
 	if err != nil {
 		slog.Debug(fmt.Sprintf("创建请求失败: %s", err))
-		return
+		return GeoInfo{}
 	}
 	defer cancel()
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("ipapi获取节点位置失败: %s", err))
-		return
+		return GeoInfo{}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		slog.Debug(fmt.Sprintf("ipapi返回非200状态码: %v", resp.StatusCode))
-		return
+		return GeoInfo{}
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("ipapi读取节点位置失败: %s", err))
-		return
+		return GeoInfo{}
 	}
 
 	var geo GeoIPData
 	err = json.Unmarshal(body, &geo)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("解析ipapi JSON 失败: %v", err))
-		return
+		return GeoInfo{}
 	}
 
-	return geo.Country, geo.IP
+	return GeoInfo{
+		IP:      geo.IP,
+		Country: geo.Country,
+		Region:  geo.Region,
+		City:    geo.City,
+		Lat:     geo.Lat,
+		Lon:     geo.Lon,
+		ASN:     geo.ASN,
+		ISP:     geo.Org,
+	}
 }
 
 func GetEdgeOneProxy(httpClient *http.Client) (loc string, ip string) {
@@ -0,0 +1,162 @@
+package proxies
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
+)
+
+const ipCacheFile = "ip-cache.json"
+
+// IP风险分类
+const (
+	RiskResidential = "residential"
+	RiskDatacenter  = "datacenter"
+	RiskProxy       = "proxy"
+	RiskAbuse       = "abuse"
+)
+
+// RiskInfo 归一化后的IP风险数据
+type RiskInfo struct {
+	Score    int    `json:"score"`              // 0-100，越高风险越大
+	Category string `json:"category,omitempty"` // residential/datacenter/proxy/abuse，无法判断时为空
+	Source   string `json:"source"`             // 数据来源
+}
+
+// ipCacheEntry 单个出口IP的缓存数据，位置和风险分别计算过期时间
+type ipCacheEntry struct {
+	Geo    *GeoInfo  `json:"geo,omitempty"`
+	GeoAt  time.Time `json:"geo_at"`
+	Risk   *RiskInfo `json:"risk,omitempty"`
+	RiskAt time.Time `json:"risk_at"`
+}
+
+var (
+	ipCacheLock sync.RWMutex
+	ipCache     map[string]*ipCacheEntry
+)
+
+func ipCacheTTL() time.Duration {
+	return time.Duration(config.GlobalConfig.IPCacheTTL) * time.Hour
+}
+
+func ipCacheEnabled() bool {
+	return config.GlobalConfig.IPCacheTTL > 0
+}
+
+// PrepareIPCache 每轮检测前调用，首次调用时从状态目录加载缓存
+func PrepareIPCache() {
+	if !ipCacheEnabled() {
+		return
+	}
+	ipCacheLock.Lock()
+	defer ipCacheLock.Unlock()
+
+	if ipCache != nil {
+		return
+	}
+	ipCache = make(map[string]*ipCacheEntry)
+	if err := utils.LoadState(ipCacheFile, &ipCache); err != nil {
+		slog.Warn(fmt.Sprintf("加载出口IP缓存失败: %v", err))
+	}
+}
+
+// SaveIPCache 清理过期数据后保存缓存
+func SaveIPCache() {
+	if !ipCacheEnabled() {
+		return
+	}
+	ipCacheLock.Lock()
+	defer ipCacheLock.Unlock()
+
+	if ipCache == nil {
+		return
+	}
+	ttl := ipCacheTTL()
+	for ip, e := range ipCache {
+		if time.Since(e.GeoAt) > ttl {
+			e.Geo = nil
+		}
+		if time.Since(e.RiskAt) > ttl {
+			e.Risk = nil
+		}
+		if e.Geo == nil && e.Risk == nil {
+			delete(ipCache, ip)
+		}
+	}
+	if err := utils.SaveState(ipCacheFile, ipCache); err != nil {
+		slog.Warn(fmt.Sprintf("保存出口IP缓存失败: %v", err))
+	}
+}
+
+func cachedGeo(ip string) (GeoInfo, bool) {
+	if ip == "" || !ipCacheEnabled() {
+		return GeoInfo{}, false
+	}
+	ipCacheLock.RLock()
+	defer ipCacheLock.RUnlock()
+
+	e := ipCache[ip]
+	if e == nil || e.Geo == nil || time.Since(e.GeoAt) > ipCacheTTL() {
+		return GeoInfo{}, false
+	}
+	return *e.Geo, true
+}
+
+func cacheGeo(ip string, geo GeoInfo) {
+	if ip == "" || !ipCacheEnabled() {
+		return
+	}
+	ipCacheLock.Lock()
+	defer ipCacheLock.Unlock()
+
+	if ipCache == nil {
+		ipCache = make(map[string]*ipCacheEntry)
+	}
+	e := ipCache[ip]
+	if e == nil {
+		e = &ipCacheEntry{}
+		ipCache[ip] = e
+	}
+	e.Geo = &geo
+	e.GeoAt = time.Now()
+}
+
+// CachedRisk 返回缓存中未过期的IP风险数据
+func CachedRisk(ip string) (RiskInfo, bool) {
+	if ip == "" || !ipCacheEnabled() {
+		return RiskInfo{}, false
+	}
+	ipCacheLock.RLock()
+	defer ipCacheLock.RUnlock()
+
+	e := ipCache[ip]
+	if e == nil || e.Risk == nil || time.Since(e.RiskAt) > ipCacheTTL() {
+		return RiskInfo{}, false
+	}
+	return *e.Risk, true
+}
+
+// CacheRisk 记录IP风险数据
+func CacheRisk(ip string, risk RiskInfo) {
+	if ip == "" || !ipCacheEnabled() {
+		return
+	}
+	ipCacheLock.Lock()
+	defer ipCacheLock.Unlock()
+
+	if ipCache == nil {
+		ipCache = make(map[string]*ipCacheEntry)
+	}
+	e := ipCache[ip]
+	if e == nil {
+		e = &ipCacheEntry{}
+		ipCache[ip] = e
+	}
+	e.Risk = &risk
+	e.RiskAt = time.Now()
+}
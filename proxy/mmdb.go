@@ -0,0 +1,264 @@
+package proxies
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// mmdbRecord 兼容 GeoLite2 Country/City/ASN 三种数据库的字段
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+	ASN uint   `maxminddb:"autonomous_system_number"`
+	Org string `maxminddb:"autonomous_system_organization"`
+}
+
+// mmdbSource 单个数据库的配置
+type mmdbSource struct {
+	kind string // country/city/asn，用于日志和默认文件名
+	path string
+	url  string
+}
+
+var (
+	mmdbLock    sync.RWMutex
+	mmdbReaders = make(map[string]*maxminddb.Reader)
+)
+
+func mmdbSources() []mmdbSource {
+	return []mmdbSource{
+		{kind: "country", path: config.GlobalConfig.MMDBCountry, url: config.GlobalConfig.MMDBCountryUrl},
+		{kind: "city", path: config.GlobalConfig.MMDBCity, url: config.GlobalConfig.MMDBCityUrl},
+		{kind: "asn", path: config.GlobalConfig.MMDBASN, url: config.GlobalConfig.MMDBASNUrl},
+	}
+}
+
+// PrepareMMDB 在每轮检测前调用，按需下载过期的数据库并重新加载
+// 未配置任何数据库时什么也不做
+func PrepareMMDB() {
+	readers := make(map[string]*maxminddb.Reader)
+	for _, src := range mmdbSources() {
+		path := src.path
+		if path == "" && src.url != "" {
+			path = filepath.Join(utils.StateDir(), "geoip-"+src.kind+".mmdb")
+		}
+		if path == "" {
+			continue
+		}
+
+		if src.url != "" && mmdbStale(path) {
+			if err := downloadMMDB(src.url, path); err != nil {
+				slog.Warn(fmt.Sprintf("下载%s数据库失败，继续使用本地文件: %v", src.kind, err))
+			} else {
+				slog.Info(fmt.Sprintf("已更新%s数据库: %s", src.kind, path))
+			}
+		}
+
+		reader, err := maxminddb.Open(path)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("加载%s数据库失败: %v", src.kind, err))
+			continue
+		}
+		readers[src.kind] = reader
+	}
+
+	mmdbLock.Lock()
+	old := mmdbReaders
+	mmdbReaders = readers
+	mmdbLock.Unlock()
+
+	for _, r := range old {
+		r.Close()
+	}
+}
+
+// mmdbStale 文件不存在或超过更新间隔时需要重新下载
+func mmdbStale(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+	hours := config.GlobalConfig.MMDBUpdateHours
+	if hours <= 0 {
+		return false
+	}
+	return time.Since(info.ModTime()) > time.Duration(hours)*time.Hour
+}
+
+func downloadMMDB(url, path string) error {
+	client := &http.Client{
+		Timeout: 5 * time.Minute,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+		},
+	}
+	resp, err := client.Get(utils.WarpUrl(url))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("返回状态码: %d", resp.StatusCode)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	f.Close()
+
+	// 确认是可用的数据库再替换，避免下载到错误页面
+	reader, err := maxminddb.Open(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("文件不是有效的mmdb: %w", err)
+	}
+	reader.Close()
+	return os.Rename(tmp, path)
+}
+
+// LookupMMDB 使用本地数据库查询IP位置，未加载数据库或查不到时 Country 为空
+func LookupMMDB(ip string) GeoInfo {
+	geo := GeoInfo{IP: ip}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return geo
+	}
+
+	mmdbLock.RLock()
+	defer mmdbLock.RUnlock()
+
+	for _, kind := range []string{"city", "country", "asn"} {
+		reader := mmdbReaders[kind]
+		if reader == nil {
+			continue
+		}
+		var rec mmdbRecord
+		if err := reader.Lookup(addr, &rec); err != nil {
+			slog.Debug(fmt.Sprintf("查询%s数据库失败: %v", kind, err))
+			continue
+		}
+		if geo.Country == "" {
+			geo.Country = rec.Country.ISOCode
+			if geo.Country == "" {
+				geo.Country = rec.RegisteredCountry.ISOCode
+			}
+		}
+		if geo.Region == "" && len(rec.Subdivisions) > 0 {
+			geo.Region = rec.Subdivisions[0].Names["en"]
+		}
+		if geo.City == "" {
+			geo.City = rec.City.Names["en"]
+		}
+		if geo.Lat == 0 && geo.Lon == 0 {
+			geo.Lat, geo.Lon = rec.Location.Latitude, rec.Location.Longitude
+		}
+		if geo.ASN == "" && rec.ASN != 0 {
+			geo.ASN = fmt.Sprintf("AS%d", rec.ASN)
+			geo.ISP = rec.Org
+		}
+	}
+	return geo
+}
+
+// mmdbLoaded 是否加载了能查询国家的数据库
+func mmdbLoaded() bool {
+	mmdbLock.RLock()
+	defer mmdbLock.RUnlock()
+	return mmdbReaders["city"] != nil || mmdbReaders["country"] != nil
+}
+
+// GetEchoIP 通过轻量的IP回显接口获取节点出口IP
+// 支持纯文本IP、cloudflare trace 格式(ip=xxx)以及带 ip 字段的 json
+func GetEchoIP(httpClient *http.Client) string {
+	url := config.GlobalConfig.IPEchoUrl
+	if url == "" {
+		url = "https://www.cloudflare.com/cdn-cgi/trace"
+	}
+	req, cancel, err := newGeoRequest(http.MethodGet, url, "curl/8.7.1")
+	if err != nil {
+		slog.Debug(fmt.Sprintf("创建请求失败: %s", err))
+		return ""
+	}
+	defer cancel()
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("IP回显接口请求失败: %s", err))
+		return ""
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.Debug(fmt.Sprintf("IP回显接口返回非200状态码: %v", resp.StatusCode))
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		slog.Debug(fmt.Sprintf("IP回显接口读取失败: %s", err))
+		return ""
+	}
+	return parseEchoIP(string(body))
+}
+
+func parseEchoIP(body string) string {
+	body = strings.TrimSpace(body)
+	if net.ParseIP(body) != nil {
+		return body
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "ip="); ok && net.ParseIP(v) != nil {
+			return v
+		}
+	}
+	var data struct {
+		IP    string `json:"ip"`
+		Query string `json:"query"`
+	}
+	if json.Unmarshal([]byte(body), &data) == nil {
+		if net.ParseIP(data.IP) != nil {
+			return data.IP
+		}
+		if net.ParseIP(data.Query) != nil {
+			return data.Query
+		}
+	}
+	return ""
+}
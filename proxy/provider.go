@@ -0,0 +1,147 @@
+package proxies
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
+	"github.com/metacubex/mihomo/common/convert"
+	"gopkg.in/yaml.v3"
+)
+
+var errNoProxies = errors.New("订阅链接没有proxies")
+
+// providerResolver 记录单个订阅内已展开的 proxy-providers 数量
+type providerResolver struct {
+	count int
+}
+
+// parseSubData 解析订阅内容，支持 clash/mihomo 与 v2ray/base64 格式
+// clash 配置中的 proxy-providers 会被展开，节点合并到父订阅中
+func parseSubData(data []byte, url string, depth int, r *providerResolver) ([]map[string]any, error) {
+	var con map[string]any
+	if err := yaml.Unmarshal(data, &con); err != nil {
+		proxyList, err := convert.ConvertsV2Ray(data)
+		if err != nil {
+			return nil, fmt.Errorf("解析proxy错误: %w", err)
+		}
+		return proxyList, nil
+	}
+
+	proxyList := toProxyMaps(con["proxies"])
+
+	if providers, ok := con["proxy-providers"].(map[string]any); ok && len(providers) > 0 {
+		proxyList = append(proxyList, r.resolve(providers, url, depth)...)
+	}
+
+	if len(proxyList) == 0 {
+		return nil, errNoProxies
+	}
+	return proxyList, nil
+}
+
+// resolve 依次展开 providers，超过深度或数量限制的直接忽略
+func (r *providerResolver) resolve(providers map[string]any, parent string, depth int) []map[string]any {
+	maxDepth := config.GlobalConfig.ProviderMaxDepth
+	if depth >= maxDepth {
+		slog.Warn("proxy-providers 嵌套层级超过限制，已忽略", "url", parent, "max-depth", maxDepth)
+		return nil
+	}
+
+	// 保证每次展开顺序一致
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result []map[string]any
+	for _, name := range names {
+		provider, ok := providers[name].(map[string]any)
+		if !ok {
+			continue
+		}
+		if r.count >= config.GlobalConfig.ProviderMaxCount {
+			slog.Warn("proxy-providers 数量超过限制，剩余的已忽略", "url", parent, "max-count", config.GlobalConfig.ProviderMaxCount)
+			break
+		}
+		r.count++
+
+		// inline 类型直接携带节点
+		if payload := toProxyMaps(provider["payload"]); len(payload) > 0 {
+			result = append(result, payload...)
+			continue
+		}
+
+		data, source, err := loadProvider(provider)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("获取proxy-provider失败: %v", err), "name", name, "url", parent)
+			continue
+		}
+		proxyList, err := parseSubData(data, source, depth+1, r)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("解析proxy-provider失败: %v", err), "name", name, "source", source)
+			continue
+		}
+		slog.Debug(fmt.Sprintf("获取proxy-provider: %s，有效节点数量: %d", source, len(proxyList)), "parent", parent)
+		result = append(result, proxyList...)
+	}
+	return result
+}
+
+// loadProvider 优先从 url 拉取 provider 内容，失败时回退到本地 path
+func loadProvider(provider map[string]any) ([]byte, string, error) {
+	var errs []error
+	if url, _ := provider["url"].(string); url != "" {
+		url = utils.WarpUrl(url)
+		data, err := GetDateFromSubs(url)
+		if err == nil {
+			return data, url, nil
+		}
+		errs = append(errs, err)
+	}
+
+	if path, _ := provider["path"].(string); path != "" {
+		full := providerPath(path)
+		data, err := os.ReadFile(full)
+		if err == nil {
+			return data, full, nil
+		}
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		return nil, "", errors.New("provider缺少url或path")
+	}
+	return nil, "", errors.Join(errs...)
+}
+
+// providerPath 将 provider 的 path 限制在 provider-base-dir 之内
+// 订阅内容不可信，绝对路径和 .. 都按相对 base 处理
+func providerPath(path string) string {
+	base := config.GlobalConfig.ProviderBaseDir
+	if base == "" {
+		base = utils.GetExecutablePath()
+	}
+	return filepath.Join(base, filepath.Clean("/"+path))
+}
+
+// toProxyMaps 将 yaml 中的节点列表转换为 map 列表
+func toProxyMaps(v any) []map[string]any {
+	list, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	result := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		if proxyMap, ok := item.(map[string]any); ok {
+			result = append(result, proxyMap)
+		}
+	}
+	return result
+}
@@ -2,12 +2,28 @@ package proxies
 
 import (
 	"fmt"
+	"log/slog"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
 )
 
+const numbersFile = "rename-numbers.json"
+
+// numberEntry 节点序号记录
+type numberEntry struct {
+	Index    int       `json:"index"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
 var (
-	counter      = make(map[string]int)
+	// numbers 分组 -> 节点指纹 -> 序号记录，跨运行持久化
+	numbers map[string]map[string]*numberEntry
+	// used 本轮已分配的序号
+	used         = make(map[string]map[int]bool)
 	counterLock  = sync.Mutex{}
 	countryAlias = map[string]string{
 		"AD": "安道尔",
@@ -262,25 +278,122 @@ var (
 	}
 )
 
-func Rename(name string) string {
+// Rename 按国家代码和 NextIndex 分配的序号生成节点名称
+func Rename(name string, index int) string {
+	code := strings.ToUpper(name)
+	if !config.GlobalConfig.NameFlag {
+		return fmt.Sprintf("%s %02d", CountryName(code), index)
+	}
+	return fmt.Sprintf("%s %s %02d", CountryCodeToFlag(code), CountryName(code), index)
+}
+
+// NextIndex 返回节点在分组内的序号，从 1 开始
+// 序号按节点指纹持久化，节点持续可用时序号不变；
+// 节点消失后其序号保留 rename-grace-hours 小时才会分配给其他节点
+func NextIndex(group string, proxy map[string]any) int {
 	counterLock.Lock()
 	defer counterLock.Unlock()
 
-	code := strings.ToUpper(name)
-	counter[code]++
-	alias := countryAlias[code]
-	if alias == "" {
-		alias = code
+	if used[group] == nil {
+		used[group] = make(map[int]bool)
+	}
+	if numbers[group] == nil {
+		numbers[group] = make(map[string]*numberEntry)
+	}
+
+	var fp string
+	if proxy != nil {
+		fp = Fingerprint(proxy)
+	}
+	now := time.Now()
+	if e, ok := numbers[group][fp]; ok && fp != "" && !used[group][e.Index] {
+		e.LastSeen = now
+		used[group][e.Index] = true
+		return e.Index
+	}
+
+	// 找一个本轮未使用、且未被宽限期内的其他节点占用的最小序号
+	held := make(map[int]bool)
+	for _, e := range numbers[group] {
+		held[e.Index] = true
+	}
+	index := 1
+	for used[group][index] || held[index] {
+		index++
+	}
+	used[group][index] = true
+	if fp != "" {
+		if _, ok := numbers[group][fp]; !ok {
+			numbers[group][fp] = &numberEntry{Index: index, LastSeen: now}
+		}
+	}
+	return index
+}
+
+// ReserveIndex 登记已经分配给节点的序号，用于恢复中断前已重命名的节点
+func ReserveIndex(group string, proxy map[string]any, index int) {
+	counterLock.Lock()
+	defer counterLock.Unlock()
+
+	if used[group] == nil {
+		used[group] = make(map[int]bool)
+	}
+	if numbers[group] == nil {
+		numbers[group] = make(map[string]*numberEntry)
+	}
+	used[group][index] = true
+	numbers[group][Fingerprint(proxy)] = &numberEntry{Index: index, LastSeen: time.Now()}
+}
+
+// ReleaseIndex 归还节点的序号，用于已重命名又被移除的节点，序号可以分配给其他节点
+func ReleaseIndex(group string, proxy map[string]any, index int) {
+	counterLock.Lock()
+	defer counterLock.Unlock()
+
+	delete(used[group], index)
+	fp := Fingerprint(proxy)
+	if e, ok := numbers[group][fp]; ok && e.Index == index {
+		delete(numbers[group], fp)
 	}
-	return fmt.Sprintf("%s %s %02d", CountryCodeToFlag(code), alias, counter[code])
 }
 
-// ResetRenameCounter 将所有计数器重置为 0
+// ResetRenameCounter 开始新一轮命名，首次调用时加载持久化的序号并清理超过宽限期的记录
 func ResetRenameCounter() {
 	counterLock.Lock()
 	defer counterLock.Unlock()
 
-	counter = make(map[string]int)
+	if numbers == nil {
+		numbers = make(map[string]map[string]*numberEntry)
+		if err := utils.LoadState(numbersFile, &numbers); err != nil {
+			slog.Warn(fmt.Sprintf("加载节点序号记录失败: %v", err))
+		}
+	}
+
+	grace := time.Duration(config.GlobalConfig.RenameGraceHours) * time.Hour
+	for group, entries := range numbers {
+		for fp, e := range entries {
+			if time.Since(e.LastSeen) > grace {
+				delete(entries, fp)
+			}
+		}
+		if len(entries) == 0 {
+			delete(numbers, group)
+		}
+	}
+	used = make(map[string]map[int]bool)
+}
+
+// SaveRenameNumbers 保存本轮分配的节点序号
+func SaveRenameNumbers() {
+	counterLock.Lock()
+	defer counterLock.Unlock()
+
+	if numbers == nil {
+		return
+	}
+	if err := utils.SaveState(numbersFile, numbers); err != nil {
+		slog.Warn(fmt.Sprintf("保存节点序号记录失败: %v", err))
+	}
 }
 
 func CountryCodeToFlag(code string) string {
@@ -0,0 +1,66 @@
+package proxies
+
+import (
+	"testing"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+func TestNextIndex(t *testing.T) {
+	config.GlobalConfig.StateDir = t.TempDir()
+	config.GlobalConfig.RenameGraceHours = 24
+	t.Cleanup(func() {
+		config.GlobalConfig.StateDir = ""
+		numbers = nil
+	})
+
+	node := func(server string) map[string]any {
+		return map[string]any{"type": "ss", "server": server, "port": 443, "cipher": "aes-128-gcm", "password": "p"}
+	}
+	a, b, c := node("1.1.1.1"), node("2.2.2.2"), node("3.3.3.3")
+
+	numbers = nil
+	ResetRenameCounter()
+	if got := NextIndex("HK", a); got != 1 {
+		t.Errorf("first run a = %d, want 1", got)
+	}
+	if got := NextIndex("HK", b); got != 2 {
+		t.Errorf("first run b = %d, want 2", got)
+	}
+	if got := NextIndex("US", c); got != 1 {
+		t.Errorf("first run c in another group = %d, want 1", got)
+	}
+	SaveRenameNumbers()
+
+	// 重新加载后节点保持原序号，a 消失时它的序号在宽限期内不分配给新节点
+	numbers = nil
+	ResetRenameCounter()
+	if got := NextIndex("HK", b); got != 2 {
+		t.Errorf("second run b = %d, want 2", got)
+	}
+	if got := NextIndex("HK", c); got != 3 {
+		t.Errorf("second run new node c = %d, want 3", got)
+	}
+
+	// 超过宽限期后 a 的序号可以被重新分配
+	counterLock.Lock()
+	for _, e := range numbers["HK"] {
+		if e.Index == 1 {
+			e.LastSeen = time.Now().Add(-25 * time.Hour)
+		}
+	}
+	counterLock.Unlock()
+	ResetRenameCounter()
+	if got := NextIndex("HK", node("4.4.4.4")); got != 1 {
+		t.Errorf("after grace new node = %d, want 1", got)
+	}
+
+	// 同一节点在一轮中出现两次时不会得到相同的序号
+	if got := NextIndex("HK", b); got != 2 {
+		t.Errorf("third run b = %d, want 2", got)
+	}
+	if got := NextIndex("HK", b); got == 2 {
+		t.Errorf("duplicate b got the same index %d", got)
+	}
+}
@@ -0,0 +1,304 @@
+package proxies
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/miekg/dns"
+)
+
+// dnsCacheTTL 解析结果缓存时间，进程内跨轮次复用
+const dnsCacheTTL = 30 * time.Minute
+
+type dnsCacheEntry struct {
+	ips     []string
+	expires time.Time
+}
+
+var (
+	dnsCache     = make(map[string]dnsCacheEntry)
+	dnsCacheLock sync.Mutex
+)
+
+// DeduplicateByDNS 解析节点域名，出口IP集合相同且其余参数一致的节点合并为一个
+func DeduplicateByDNS(proxies []map[string]any) ([]map[string]any, int) {
+	hosts := make(map[string]bool)
+	for _, proxy := range proxies {
+		server, _ := proxy["server"].(string)
+		server = strings.ToLower(strings.TrimSpace(server))
+		if server != "" && net.ParseIP(server) == nil {
+			hosts[server] = true
+		}
+	}
+	if len(hosts) == 0 {
+		return proxies, 0
+	}
+
+	resolved := resolveHosts(hosts)
+	slog.Info(fmt.Sprintf("DNS解析域名数量: %d，成功: %d", len(hosts), len(resolved)))
+
+	seenKeys := make(map[string]bool, len(proxies))
+	result := make([]map[string]any, 0, len(proxies))
+	for _, proxy := range proxies {
+		key := dnsFingerprint(proxy, resolved)
+		if seenKeys[key] {
+			continue
+		}
+		seenKeys[key] = true
+		result = append(result, proxy)
+	}
+	return result, len(proxies) - len(result)
+}
+
+// dnsFingerprint 用解析后的IP集合替换 server 计算指纹，解析失败的保持原样
+// 使用 TLS 且没有指定 SNI 的节点以原 server 作为 SNI，域名节点和IP节点握手不同，不会被合并
+func dnsFingerprint(proxy map[string]any, resolved map[string][]string) string {
+	server, _ := proxy["server"].(string)
+	server = strings.ToLower(strings.TrimSpace(server))
+	var ips []string
+	if ip := net.ParseIP(server); ip != nil {
+		ips = []string{ip.String()}
+	} else {
+		ips = resolved[server]
+	}
+	if len(ips) == 0 {
+		return Fingerprint(proxy)
+	}
+
+	tmp := make(map[string]any, len(proxy))
+	for k, v := range proxy {
+		tmp[k] = v
+	}
+	tmp["server"] = strings.Join(preferIPv4(ips), ",")
+	if field := sniField(proxy); field != "" {
+		if sni, _ := proxy[field].(string); sni == "" {
+			tmp[field] = server
+		}
+	}
+	return Fingerprint(tmp)
+}
+
+// sniField 节点使用 TLS 时返回 SNI 对应的字段名，不使用 TLS 时返回空
+func sniField(proxy map[string]any) string {
+	t, _ := proxy["type"].(string)
+	tls, _ := proxy["tls"].(bool)
+	switch strings.ToLower(t) {
+	case "trojan", "hysteria", "hysteria2", "hy2", "tuic", "anytls":
+		return "sni"
+	case "vmess", "vless":
+		if tls {
+			return "servername"
+		}
+	case "http":
+		if tls {
+			return "sni"
+		}
+	}
+	return ""
+}
+
+// preferIPv4 有 IPv4 地址时只用 IPv4 比较，双栈域名可以和只写了 IPv4 的节点合并
+func preferIPv4(ips []string) []string {
+	var v4 []string
+	for _, ip := range ips {
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+			v4 = append(v4, ip)
+		}
+	}
+	if len(v4) == 0 {
+		return ips
+	}
+	return v4
+}
+
+// resolveHosts 并发解析域名，并发数受 dns-dedup-concurrent 限制
+func resolveHosts(hosts map[string]bool) map[string][]string {
+	concurrent := config.GlobalConfig.DNSDedupConcurrent
+	if concurrent <= 0 {
+		concurrent = 20
+	}
+
+	var (
+		wg       sync.WaitGroup
+		lock     sync.Mutex
+		resolved = make(map[string][]string, len(hosts))
+		limit    = make(chan struct{}, concurrent)
+	)
+	for host := range hosts {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(host string) {
+			defer wg.Done()
+			defer func() { <-limit }()
+
+			ips, err := lookupHost(host)
+			if err != nil {
+				slog.Debug(fmt.Sprintf("DNS解析失败: %v", err), "host", host)
+				return
+			}
+			lock.Lock()
+			resolved[host] = ips
+			lock.Unlock()
+		}(host)
+	}
+	wg.Wait()
+	return resolved
+}
+
+// lookupHost 解析域名，返回排序后的IP列表，优先使用缓存
+func lookupHost(host string) ([]string, error) {
+	dnsCacheLock.Lock()
+	entry, ok := dnsCache[host]
+	dnsCacheLock.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.ips, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var (
+		ips []string
+		err error
+	)
+	server := config.GlobalConfig.DNSDedupServer
+	switch {
+	case server == "":
+		ips, err = net.DefaultResolver.LookupHost(ctx, host)
+	case strings.HasPrefix(server, "https://"):
+		ips, err = lookupDoH(ctx, server, host)
+	default:
+		ips, err = lookupDNS(ctx, server, host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("没有解析结果")
+	}
+
+	for i, ip := range ips {
+		if parsed := net.ParseIP(ip); parsed != nil {
+			ips[i] = parsed.String()
+		}
+	}
+	sort.Strings(ips)
+
+	dnsCacheLock.Lock()
+	dnsCache[host] = dnsCacheEntry{ips: ips, expires: time.Now().Add(dnsCacheTTL)}
+	dnsCacheLock.Unlock()
+	return ips, nil
+}
+
+// lookupDNS 使用传统 DNS 查询 A/AAAA 记录，server 支持 udp://、tcp:// 前缀，默认 udp 53 端口
+func lookupDNS(ctx context.Context, server, host string) ([]string, error) {
+	network := "udp"
+	if after, ok := strings.CutPrefix(server, "tcp://"); ok {
+		network, server = "tcp", after
+	} else {
+		server = strings.TrimPrefix(server, "udp://")
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	client := &dns.Client{Net: network}
+	var (
+		ips     []string
+		lastErr error
+	)
+	// A 和 AAAA 分别查询，只要有一个成功就使用成功的结果
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(host), qtype)
+		resp, _, err := client.ExchangeContext(ctx, msg, server)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ips = append(ips, answerIPs(resp)...)
+	}
+	if len(ips) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return ips, nil
+}
+
+// lookupDoH 使用 DNS over HTTPS (RFC 8484) 查询 A/AAAA 记录，只要有一个成功就使用成功的结果
+func lookupDoH(ctx context.Context, server, host string) ([]string, error) {
+	var (
+		ips     []string
+		lastErr error
+	)
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		answer, err := queryDoH(ctx, server, host, qtype)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ips = append(ips, answerIPs(answer)...)
+	}
+	if len(ips) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return ips, nil
+}
+
+func queryDoH(ctx context.Context, server, host string, qtype uint16) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(host), qtype)
+	msg.Id = 0
+	data, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH返回非200状态码: %d", resp.StatusCode)
+	}
+
+	answer := new(dns.Msg)
+	if err := answer.Unpack(body); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+func answerIPs(msg *dns.Msg) []string {
+	var ips []string
+	for _, rr := range msg.Answer {
+		switch record := rr.(type) {
+		case *dns.A:
+			ips = append(ips, record.A.String())
+		case *dns.AAAA:
+			ips = append(ips, record.AAAA.String())
+		}
+	}
+	return ips
+}
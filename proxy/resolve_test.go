@@ -0,0 +1,75 @@
+package proxies
+
+import (
+	"testing"
+)
+
+func TestDNSFingerprint(t *testing.T) {
+	resolved := map[string][]string{
+		"a.com":     {"1.1.1.1"},
+		"dual.com":  {"1.1.1.1", "2606:4700::1111"},
+		"other.com": {"2.2.2.2"},
+	}
+	tests := []struct {
+		name string
+		a, b map[string]any
+		same bool
+	}{
+		{
+			name: "ss host and ip",
+			a:    map[string]any{"type": "ss", "server": "a.com", "port": 443, "cipher": "aes-128-gcm", "password": "p"},
+			b:    map[string]any{"type": "ss", "server": "1.1.1.1", "port": 443, "cipher": "aes-128-gcm", "password": "p"},
+			same: true,
+		},
+		{
+			name: "trojan ip without sni",
+			a:    map[string]any{"type": "trojan", "server": "a.com", "port": 443, "password": "p"},
+			b:    map[string]any{"type": "trojan", "server": "1.1.1.1", "port": 443, "password": "p"},
+			same: false,
+		},
+		{
+			name: "trojan ip with sni",
+			a:    map[string]any{"type": "trojan", "server": "a.com", "port": 443, "password": "p"},
+			b:    map[string]any{"type": "trojan", "server": "1.1.1.1", "port": 443, "password": "p", "sni": "A.com"},
+			same: true,
+		},
+		{
+			name: "vmess without tls",
+			a:    map[string]any{"type": "vmess", "server": "a.com", "port": 80, "uuid": "u"},
+			b:    map[string]any{"type": "vmess", "server": "1.1.1.1", "port": 80, "uuid": "u"},
+			same: true,
+		},
+		{
+			name: "vmess tls ip without servername",
+			a:    map[string]any{"type": "vmess", "server": "a.com", "port": 443, "uuid": "u", "tls": true},
+			b:    map[string]any{"type": "vmess", "server": "1.1.1.1", "port": 443, "uuid": "u", "tls": true},
+			same: false,
+		},
+		{
+			name: "dual stack host and ipv4",
+			a:    map[string]any{"type": "ss", "server": "dual.com", "port": 443, "cipher": "aes-128-gcm", "password": "p"},
+			b:    map[string]any{"type": "ss", "server": "1.1.1.1", "port": 443, "cipher": "aes-128-gcm", "password": "p"},
+			same: true,
+		},
+		{
+			name: "different ip",
+			a:    map[string]any{"type": "ss", "server": "other.com", "port": 443, "cipher": "aes-128-gcm", "password": "p"},
+			b:    map[string]any{"type": "ss", "server": "1.1.1.1", "port": 443, "cipher": "aes-128-gcm", "password": "p"},
+			same: false,
+		},
+		{
+			name: "unresolved host",
+			a:    map[string]any{"type": "ss", "server": "missing.com", "port": 443, "cipher": "aes-128-gcm", "password": "p"},
+			b:    map[string]any{"type": "ss", "server": "1.1.1.1", "port": 443, "cipher": "aes-128-gcm", "password": "p"},
+			same: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dnsFingerprint(tt.a, resolved) == dnsFingerprint(tt.b, resolved)
+			if got != tt.same {
+				t.Errorf("dnsFingerprint() equal = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
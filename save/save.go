@@ -5,6 +5,9 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/beck-8/subs-check/check"
@@ -19,8 +22,12 @@ type ProxyCategory struct {
 	Name    string
 	Proxies []map[string]any
 	Filter  func(result check.Result) bool
+	Custom  bool // output-categories 中配置的分类，直接保存为 yaml
 }
 
+// outputGroupsFile 记录上次输出的分组文件，用于清空不再有节点的分组
+const outputGroupsFile = "output-groups.json"
+
 // ConfigSaver 处理配置保存的结构体
 type ConfigSaver struct {
 	results    []check.Result
@@ -34,7 +41,7 @@ var internalHTTPClient = &http.Client{
 
 // NewConfigSaver 创建新的配置保存器
 func NewConfigSaver(results []check.Result) *ConfigSaver {
-	return &ConfigSaver{
+	cs := &ConfigSaver{
 		results:    results,
 		saveMethod: chooseSaveMethod(),
 		categories: []ProxyCategory{
@@ -55,6 +62,145 @@ func NewConfigSaver(results []check.Result) *ConfigSaver {
 			},
 		},
 	}
+	var groups []string
+	for _, c := range config.GlobalConfig.OutputCategories {
+		if c.Name == "" {
+			continue
+		}
+		if cs.builtin(c.Name) {
+			slog.Error(fmt.Sprintf("output-categories 的文件名与内置输出重名，已忽略: %s", c.Name))
+			continue
+		}
+		if c.GroupBy != "" {
+			for _, category := range groupCategories(c, results) {
+				if cs.builtin(category.Name) {
+					slog.Error(fmt.Sprintf("分组文件名与内置输出重名，已忽略: %s", category.Name))
+					continue
+				}
+				cs.categories = append(cs.categories, category)
+				groups = append(groups, category.Name)
+			}
+			continue
+		}
+		cs.categories = append(cs.categories, ProxyCategory{
+			Name:    c.Name,
+			Proxies: make([]map[string]any, 0),
+			Filter:  outputFilter(c),
+			Custom:  true,
+		})
+	}
+	cs.categories = append(cs.categories, staleGroups(groups)...)
+	return cs
+}
+
+// builtin 是否为内置输出的文件名
+func (cs *ConfigSaver) builtin(name string) bool {
+	for _, category := range cs.categories {
+		if !category.Custom && strings.EqualFold(category.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// staleGroups 上次输出过、这次没有节点的分组文件，保存为空列表以免继续提供失效节点
+// 分组文件名按保存方式记录在状态目录中
+func staleGroups(groups []string) []ProxyCategory {
+	saved := make(map[string][]string)
+	if err := utils.LoadState(outputGroupsFile, &saved); err != nil {
+		slog.Warn(fmt.Sprintf("加载分组输出记录失败: %v", err))
+	}
+	method := config.GlobalConfig.SaveMethod
+
+	var stale []ProxyCategory
+	for _, name := range saved[method] {
+		if slices.Contains(groups, name) {
+			continue
+		}
+		slog.Info("分组没有可用节点，清空文件", "file", name)
+		stale = append(stale, ProxyCategory{
+			Name:    name,
+			Proxies: make([]map[string]any, 0),
+			Filter:  func(result check.Result) bool { return false },
+			Custom:  true,
+		})
+	}
+
+	saved[method] = groups
+	if err := utils.SaveState(outputGroupsFile, saved); err != nil {
+		slog.Warn(fmt.Sprintf("保存分组输出记录失败: %v", err))
+	}
+	return stale
+}
+
+// outputFilter 根据 output-categories 的条件生成筛选函数
+func outputFilter(c config.OutputCategory) func(result check.Result) bool {
+	match := func(values []string, v string) bool {
+		return len(values) == 0 || slices.ContainsFunc(values, func(s string) bool { return strings.EqualFold(s, v) })
+	}
+	return func(result check.Result) bool {
+		return match(c.IPTypes, result.IPType) &&
+			match(c.Countries, result.Country) &&
+			match(c.Regions, result.Region) &&
+			match(c.Cities, result.City)
+	}
+}
+
+// groupCategories 按出口位置把一个输出拆分成多个文件，位置未知的节点不输出
+func groupCategories(c config.OutputCategory, results []check.Result) []ProxyCategory {
+	filter := outputFilter(c)
+	var categories []ProxyCategory
+	seen := make(map[string]bool)
+	for _, result := range results {
+		key := groupKey(c.GroupBy, result)
+		if key == "" || seen[key] || !filter(result) {
+			continue
+		}
+		seen[key] = true
+		categories = append(categories, ProxyCategory{
+			Name:    groupFileName(c.Name, key),
+			Proxies: make([]map[string]any, 0),
+			Filter: func(result check.Result) bool {
+				return filter(result) && groupKey(c.GroupBy, result) == key
+			},
+			Custom: true,
+		})
+	}
+	return categories
+}
+
+// groupKey 返回节点的分组名，地区和城市带上国家代码以免重名
+func groupKey(groupBy string, result check.Result) string {
+	country := strings.ToUpper(result.Country)
+	if country == "" {
+		return ""
+	}
+	var key string
+	switch strings.ToLower(groupBy) {
+	case "country":
+		key = country
+	case "region":
+		if result.Region == "" {
+			return ""
+		}
+		key = country + "-" + result.Region
+	case "city":
+		if result.City == "" {
+			return ""
+		}
+		key = country + "-" + result.City
+	default:
+		return ""
+	}
+	return strings.NewReplacer(" ", "_", "/", "_", "\\", "_").Replace(key)
+}
+
+func groupFileName(name, key string) string {
+	if strings.Contains(name, "{group}") {
+		return strings.ReplaceAll(name, "{group}", key)
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-" + key + ext
 }
 
 // SaveConfig 保存配置的入口函数
@@ -114,7 +260,8 @@ func (cs *ConfigSaver) categorizeProxies() {
 
 // saveCategory 保存单个类别的代理
 func (cs *ConfigSaver) saveCategory(category ProxyCategory) error {
-	if len(category.Proxies) == 0 {
+	// 自定义输出即使为空也要保存，避免继续提供上次的节点
+	if len(category.Proxies) == 0 && !category.Custom {
 		slog.Warn(fmt.Sprintf("yaml节点为空，跳过保存: %s, saveMethod: %s", category.Name, config.GlobalConfig.SaveMethod))
 		return nil
 	}
@@ -135,6 +282,18 @@ func (cs *ConfigSaver) saveCategory(category ProxyCategory) error {
 		}
 		return nil
 	}
+	if category.Custom {
+		yamlData, err := yaml.Marshal(map[string]any{
+			"proxies": category.Proxies,
+		})
+		if err != nil {
+			return fmt.Errorf("序列化yaml %s 失败: %w", category.Name, err)
+		}
+		if err := cs.saveMethod(yamlData, category.Name); err != nil {
+			return fmt.Errorf("保存 %s 失败: %w", category.Name, err)
+		}
+		return nil
+	}
 	if category.Name == "mihomo.yaml" && config.GlobalConfig.SubStorePort != "" {
 		resp, err := internalHTTPClient.Get(fmt.Sprintf("%s/api/file/%s", utils.BaseURL, utils.MihomoName))
 		if err != nil {
@@ -5,7 +5,6 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
-	"sync/atomic"
 	"syscall"
 )
 
@@ -14,9 +13,9 @@ import (
 
 // SetupSignalHandler 设置信号处理
 // 同时支持两种信号处理模式：
-// - HUB 信号(SIGHUP): 只设置 check.ForceClose 为 true，不退出程序
+// - HUB 信号(SIGHUP): 只调用 forceClose 结束当前检测，不退出程序
 // - Ctrl+C 信号(SIGINT/SIGTERM): 第一次设置 ForceClose，第二次退出程序
-func SetupSignalHandler(forceClose *atomic.Bool) {
+func SetupSignalHandler(forceClose func()) {
 	slog.Debug("设置信号处理器")
 
 	// 监听 SIGINT (Ctrl+C)
@@ -55,9 +54,9 @@ func SetupSignalHandler(forceClose *atomic.Bool) {
 		for sig := range hubSigChan {
 			slog.Debug(fmt.Sprintf("收到 HUB 信号: %s", sig))
 
-			// HUB 信号只设置 ForceClose，不退出程序
-			forceClose.Store(true)
-			slog.Debug("HUB 模式: 已设置强制关闭标志，任务将自动结束，程序继续运行")
+			// HUB 信号只结束当前检测，不退出程序
+			forceClose()
+			slog.Debug("HUB 模式: 已取消当前检测，正在进行的任务会立即中止，程序继续运行")
 		}
 	}()
 }
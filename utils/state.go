@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+// StateDir 返回跨运行持久化数据的保存目录
+// 默认放在程序目录的 config/state 下，docker 挂载 config 目录即可保留
+func StateDir() string {
+	if config.GlobalConfig.StateDir != "" {
+		return config.GlobalConfig.StateDir
+	}
+	return filepath.Join(GetExecutablePath(), "config", "state")
+}
+
+// LoadState 从状态目录读取 json 文件，文件不存在时保持 v 不变
+func LoadState(name string, v any) error {
+	data, err := os.ReadFile(filepath.Join(StateDir(), name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取状态文件失败 [%s]: %w", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("解析状态文件失败 [%s]: %w", name, err)
+	}
+	return nil
+}
+
+// SaveState 将 v 以 json 格式写入状态目录，先写临时文件再替换，避免写一半被中断
+func SaveState(name string, v any) error {
+	dir := StateDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建状态目录失败 [%s]: %w", dir, err)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("序列化状态失败 [%s]: %w", name, err)
+	}
+	path := filepath.Join(dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入状态文件失败 [%s]: %w", name, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("替换状态文件失败 [%s]: %w", name, err)
+	}
+	return nil
+}
+
+// RemoveState 删除状态目录中的文件，文件不存在时不报错
+func RemoveState(name string) error {
+	err := os.Remove(filepath.Join(StateDir(), name))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除状态文件失败 [%s]: %w", name, err)
+	}
+	return nil
+}